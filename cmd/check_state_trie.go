@@ -0,0 +1,121 @@
+// VulcanizeDB
+// Copyright © 2023 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/cerc-io/plugeth-statediff/indexer/database/sql/postgres"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/cerc-io/ipld-eth-db-validator/v5/pkg/validator"
+)
+
+var (
+	checkTrieBlockNumber int64
+	checkTrieBlockHash   string
+	checkTrieStorage     bool
+	checkTrieOutput      string
+)
+
+// checkStateTrieCmd represents the checkStateTrie command
+var checkStateTrieCmd = &cobra.Command{
+	Use:   "checkStateTrie",
+	Short: "Check that the whole state trie at a block is indexed and exit",
+	Long: `Usage ./ipld-eth-db-validator checkStateTrie --config={path to toml config file} --block={block number} [--storage]
+       ./ipld-eth-db-validator checkStateTrie --config={path to toml config file} --hash={block hash} [--storage]`,
+
+	Run: func(cmd *cobra.Command, args []string) {
+		subCommand = cmd.CalledAs()
+		logWithCommand = *log.WithField("SubCommand", subCommand)
+		checkStateTrie()
+	},
+}
+
+func checkStateTrie() {
+	if (checkTrieBlockNumber < 0) == (checkTrieBlockHash == "") {
+		logWithCommand.Fatal("exactly one of --block or --hash must be provided")
+	}
+	if checkTrieOutput != "text" && checkTrieOutput != "json" {
+		logWithCommand.Fatalf("invalid output format %q (text, json)", checkTrieOutput)
+	}
+
+	cfg, err := validator.NewEthConfig()
+	if err != nil {
+		logWithCommand.Fatal(err)
+	}
+
+	ctx := context.Background()
+	db, err := postgres.ConnectSQLX(ctx, cfg.DBConfig)
+	if err != nil {
+		logWithCommand.Fatal(err)
+	}
+	defer db.Close()
+
+	api, err := validator.EthAPI(ctx, db, cfg.ChainConfig)
+	if err != nil {
+		logWithCommand.Fatal(err)
+	}
+	defer api.B.Close()
+
+	block, err := fetchBlock(ctx, api.B, checkTrieBlockNumber, checkTrieBlockHash)
+	if err != nil {
+		logWithCommand.Fatal(err)
+	}
+
+	result, err := validator.CheckStateTrie(ctx, api.B, block.Header(), checkTrieStorage)
+	if err != nil {
+		logWithCommand.Fatal(err)
+	}
+	if checkTrieOutput == "json" {
+		out, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			logWithCommand.Fatal(err)
+		}
+		fmt.Println(string(out))
+	} else {
+		fmt.Printf("block %d (%s), state root %s\n", result.BlockNumber, result.BlockHash.Hex(), result.StateRoot.Hex())
+		for _, missing := range result.Missing {
+			fmt.Printf("  missing node %s\n", missing)
+		}
+		if result.Complete() {
+			fmt.Printf("COMPLETE: %d nodes in %s\n", result.Nodes, result.Duration)
+		} else {
+			fmt.Printf("INCOMPLETE: %d nodes found, %d missing in %s\n", result.Nodes, len(result.Missing), result.Duration)
+		}
+	}
+
+	if !result.Complete() {
+		// deferred cleanup is skipped by os.Exit
+		api.B.Close()
+		db.Close()
+		os.Exit(1)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(checkStateTrieCmd)
+
+	checkStateTrieCmd.Flags().Int64Var(&checkTrieBlockNumber, "block", -1, "number of the block to check")
+	checkStateTrieCmd.Flags().StringVar(&checkTrieBlockHash, "hash", "", "hash of the block to check")
+	checkStateTrieCmd.Flags().BoolVar(&checkTrieStorage, "storage", false, "whether to also check every storage trie")
+	checkStateTrieCmd.Flags().StringVar(&checkTrieOutput, "output", "text", "output format (text, json)")
+}
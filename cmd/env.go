@@ -30,6 +30,10 @@ const (
 	PROM_HTTP_PORT = "PROM_HTTP_PORT"
 	PROM_DB_STATS  = "PROM_DB_STATS"
 
+	SERVER_HTTP      = "SERVER_HTTP"
+	SERVER_HTTP_ADDR = "SERVER_HTTP_ADDR"
+	SERVER_HTTP_PORT = "SERVER_HTTP_PORT"
+
 	DATABASE_NAME     = "DATABASE_NAME"
 	DATABASE_HOSTNAME = "DATABASE_HOSTNAME"
 	DATABASE_PORT     = "DATABASE_PORT"
@@ -44,11 +48,29 @@ const (
 	ETH_CHAIN_ID     = "ETH_CHAIN_ID"
 	ETH_HTTP_PATH    = "ETH_HTTP_PATH"
 
+	VALIDATE_ID                      = "VALIDATE_ID"
+	VALIDATE_IGNORE_CHECKPOINT       = "VALIDATE_IGNORE_CHECKPOINT"
 	VALIDATE_FROM_BLOCK              = "VALIDATE_FROM_BLOCK"
+	VALIDATE_TO_BLOCK                = "VALIDATE_TO_BLOCK"
 	VALIDATE_TRAIL                   = "VALIDATE_TRAIL"
+	VALIDATE_WORKERS                 = "VALIDATE_WORKERS"
+	VALIDATE_ALL_FORKS               = "VALIDATE_ALL_FORKS"
+	VALIDATE_REORG_WINDOW            = "VALIDATE_REORG_WINDOW"
 	VALIDATE_RETRY_INTERVAL          = "VALIDATE_RETRY_INTERVAL"
 	VALIDATE_STATEDIFF_MISSING_BLOCK = "VALIDATE_STATEDIFF_MISSING_BLOCK"
 	VALIDATE_STATEDIFF_TIMEOUT       = "VALIDATE_STATEDIFF_TIMEOUT"
+	VALIDATE_MISSING_BLOCK_POLICY    = "VALIDATE_MISSING_BLOCK_POLICY"
+	VALIDATE_FAILURE_POLICY          = "VALIDATE_FAILURE_POLICY"
+	VALIDATE_FAILURE_RETRIES         = "VALIDATE_FAILURE_RETRIES"
+	VALIDATE_TRIE_CHECK_INTERVAL     = "VALIDATE_TRIE_CHECK_INTERVAL"
+	VALIDATE_TRIE_CHECK_STORAGE      = "VALIDATE_TRIE_CHECK_STORAGE"
+
+	GAPS_FROM_BLOCK  = "GAPS_FROM_BLOCK"
+	GAPS_TO_BLOCK    = "GAPS_TO_BLOCK"
+	GAPS_FILL        = "GAPS_FILL"
+	GAPS_BATCH_SIZE  = "GAPS_BATCH_SIZE"
+	GAPS_CONCURRENCY = "GAPS_CONCURRENCY"
+	GAPS_RETRIES     = "GAPS_RETRIES"
 )
 
 // Bind env vars
@@ -62,6 +84,10 @@ func init() {
 	viper.BindEnv("prom.httpPort", PROM_HTTP_PORT)
 	viper.BindEnv("prom.dbStats", PROM_DB_STATS)
 
+	viper.BindEnv("server.http", SERVER_HTTP)
+	viper.BindEnv("server.httpAddr", SERVER_HTTP_ADDR)
+	viper.BindEnv("server.httpPort", SERVER_HTTP_PORT)
+
 	viper.BindEnv("database.name", DATABASE_NAME)
 	viper.BindEnv("database.hostname", DATABASE_HOSTNAME)
 	viper.BindEnv("database.port", DATABASE_PORT)
@@ -76,9 +102,27 @@ func init() {
 	viper.BindEnv("ethereum.chainID", ETH_CHAIN_ID)
 	viper.BindEnv("ethereum.httpPath", ETH_HTTP_PATH)
 
+	viper.BindEnv("validate.id", VALIDATE_ID)
+	viper.BindEnv("validate.ignoreCheckpoint", VALIDATE_IGNORE_CHECKPOINT)
 	viper.BindEnv("validate.fromBlock", VALIDATE_FROM_BLOCK)
+	viper.BindEnv("validate.toBlock", VALIDATE_TO_BLOCK)
 	viper.BindEnv("validate.trail", VALIDATE_TRAIL)
+	viper.BindEnv("validate.workers", VALIDATE_WORKERS)
+	viper.BindEnv("validate.allForks", VALIDATE_ALL_FORKS)
+	viper.BindEnv("validate.reorgWindow", VALIDATE_REORG_WINDOW)
 	viper.BindEnv("validate.retryInterval", VALIDATE_RETRY_INTERVAL)
 	viper.BindEnv("validate.stateDiffMissingBlock", VALIDATE_STATEDIFF_MISSING_BLOCK)
 	viper.BindEnv("validate.stateDiffTimeout", VALIDATE_STATEDIFF_TIMEOUT)
+	viper.BindEnv("validate.missingBlockPolicy", VALIDATE_MISSING_BLOCK_POLICY)
+	viper.BindEnv("validate.failurePolicy", VALIDATE_FAILURE_POLICY)
+	viper.BindEnv("validate.failureRetries", VALIDATE_FAILURE_RETRIES)
+	viper.BindEnv("validate.trieCheckInterval", VALIDATE_TRIE_CHECK_INTERVAL)
+	viper.BindEnv("validate.trieCheckStorage", VALIDATE_TRIE_CHECK_STORAGE)
+
+	viper.BindEnv("gaps.fromBlock", GAPS_FROM_BLOCK)
+	viper.BindEnv("gaps.toBlock", GAPS_TO_BLOCK)
+	viper.BindEnv("gaps.fill", GAPS_FILL)
+	viper.BindEnv("gaps.batchSize", GAPS_BATCH_SIZE)
+	viper.BindEnv("gaps.concurrency", GAPS_CONCURRENCY)
+	viper.BindEnv("gaps.retries", GAPS_RETRIES)
 }
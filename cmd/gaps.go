@@ -0,0 +1,125 @@
+// VulcanizeDB
+// Copyright © 2023 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/cerc-io/plugeth-statediff/indexer/database/sql/postgres"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/cerc-io/ipld-eth-db-validator/v5/pkg/validator"
+)
+
+// gapsCmd represents the gaps command
+var gapsCmd = &cobra.Command{
+	Use:   "gaps",
+	Short: "Find and optionally fill gaps in the index",
+	Long:  `Usage ./ipld-eth-db-validator gaps --config={path to toml config file} --from-block={start} --to-block={end} [--fill]`,
+
+	Run: func(cmd *cobra.Command, args []string) {
+		subCommand = cmd.CalledAs()
+		logWithCommand = *log.WithField("SubCommand", subCommand)
+		gaps()
+	},
+}
+
+func gaps() {
+	cfg, err := validator.NewEthConfig()
+	if err != nil {
+		logWithCommand.Fatal(err)
+	}
+
+	fromBlock := viper.GetUint64("gaps.fromBlock")
+	toBlock := viper.GetUint64("gaps.toBlock")
+	if toBlock != 0 && toBlock < fromBlock {
+		logWithCommand.Fatal("ending block height cannot be less than starting block height")
+	}
+	fill := viper.GetBool("gaps.fill")
+	if fill && cfg.Client == nil {
+		logWithCommand.Fatal("filling gaps requires a statediffing node at ethereum.httpPath")
+	}
+
+	ctx := context.Background()
+	db, err := postgres.ConnectSQLX(ctx, cfg.DBConfig)
+	if err != nil {
+		logWithCommand.Fatal(err)
+	}
+	defer db.Close()
+
+	found, err := validator.FindGaps(db, fromBlock, toBlock)
+	if err != nil {
+		logWithCommand.Fatal(err)
+	}
+	for _, gap := range found {
+		if gap.BlockHash == "" {
+			fmt.Printf("block %d: %s\n", gap.BlockNumber, gap.Reason)
+		} else {
+			fmt.Printf("block %d (%s): %s\n", gap.BlockNumber, gap.BlockHash, gap.Reason)
+		}
+	}
+	heights := validator.GapHeights(found)
+	logWithCommand.Infof("found gaps at %d heights", len(heights))
+	if len(heights) == 0 {
+		return
+	}
+	if !fill {
+		db.Close()
+		os.Exit(1)
+	}
+
+	failed := validator.FillGaps(ctx, cfg.Client, heights, validator.GapFillConfig{
+		BatchSize:     viper.GetUint("gaps.batchSize"),
+		Concurrency:   viper.GetUint("gaps.concurrency"),
+		Retries:       viper.GetUint("gaps.retries"),
+		RetryInterval: viper.GetDuration("validate.retryInterval"),
+		Timeout:       viper.GetDuration("validate.stateDiffTimeout"),
+	})
+	for _, height := range heights {
+		if err, ok := failed[height]; ok {
+			logWithCommand.Errorf("failed to fill gap at block %d: %s", height, err)
+		}
+	}
+	if len(failed) != 0 {
+		logWithCommand.Errorf("failed to fill gaps at %d of %d heights", len(failed), len(heights))
+		db.Close()
+		os.Exit(1)
+	}
+	logWithCommand.Infof("filled gaps at %d heights", len(heights))
+}
+
+func init() {
+	rootCmd.AddCommand(gapsCmd)
+
+	gapsCmd.PersistentFlags().String("from-block", "1", "block height to start scanning for gaps at")
+	gapsCmd.PersistentFlags().String("to-block", "0", "block height to end scanning for gaps at (0 for the highest indexed header)")
+	gapsCmd.PersistentFlags().Bool("fill", false, "whether to call writeStateDiffAt for each gap found")
+	gapsCmd.PersistentFlags().String("batch-size", "10", "number of writeStateDiffAt calls per batch")
+	gapsCmd.PersistentFlags().String("concurrency", "2", "number of batches of writeStateDiffAt calls in flight at once")
+	gapsCmd.PersistentFlags().String("retries", "3", "number of times to retry a failed writeStateDiffAt call")
+
+	_ = viper.BindPFlag("gaps.fromBlock", gapsCmd.PersistentFlags().Lookup("from-block"))
+	_ = viper.BindPFlag("gaps.toBlock", gapsCmd.PersistentFlags().Lookup("to-block"))
+	_ = viper.BindPFlag("gaps.fill", gapsCmd.PersistentFlags().Lookup("fill"))
+	_ = viper.BindPFlag("gaps.batchSize", gapsCmd.PersistentFlags().Lookup("batch-size"))
+	_ = viper.BindPFlag("gaps.concurrency", gapsCmd.PersistentFlags().Lookup("concurrency"))
+	_ = viper.BindPFlag("gaps.retries", gapsCmd.PersistentFlags().Lookup("retries"))
+}
@@ -82,6 +82,10 @@ func init() {
 	rootCmd.PersistentFlags().String("log-file", "", "file path for logging")
 	rootCmd.PersistentFlags().String("log-level", log.InfoLevel.String(), "Log level (trace, debug, info, warn, error, fatal, panic")
 
+	rootCmd.PersistentFlags().String("eth-chain-config", "", "path to json chain config")
+	rootCmd.PersistentFlags().String("eth-chain-id", "1", "eth chain id")
+	rootCmd.PersistentFlags().String("eth-http-path", "", "http url for a statediffing node")
+
 	rootCmd.PersistentFlags().Bool("prom-metrics", false, "enable prometheus metrics")
 	rootCmd.PersistentFlags().Bool("prom-http", false, "enable prometheus http service")
 	rootCmd.PersistentFlags().String("prom-httpAddr", "127.0.0.1", "prometheus http host")
@@ -96,6 +100,10 @@ func init() {
 	_ = viper.BindPFlag("log.file", rootCmd.PersistentFlags().Lookup("log-file"))
 	_ = viper.BindPFlag("log.level", rootCmd.PersistentFlags().Lookup("log-level"))
 
+	_ = viper.BindPFlag("ethereum.chainConfig", rootCmd.PersistentFlags().Lookup("eth-chain-config"))
+	_ = viper.BindPFlag("ethereum.chainID", rootCmd.PersistentFlags().Lookup("eth-chain-id"))
+	_ = viper.BindPFlag("ethereum.httpPath", rootCmd.PersistentFlags().Lookup("eth-http-path"))
+
 	_ = viper.BindPFlag("prom.metrics", rootCmd.PersistentFlags().Lookup("prom-metrics"))
 	_ = viper.BindPFlag("prom.http", rootCmd.PersistentFlags().Lookup("prom-http"))
 	_ = viper.BindPFlag("prom.httpAddr", rootCmd.PersistentFlags().Lookup("prom-httpAddr"))
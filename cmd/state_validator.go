@@ -54,39 +54,90 @@ func stateValidator() {
 		logWithCommand.Fatal(err)
 	}
 
+	if viper.GetBool("server.http") {
+		addr := fmt.Sprintf(
+			"%s:%s",
+			viper.GetString("server.httpAddr"),
+			viper.GetString("server.httpPort"),
+		)
+		logWithCommand.Info("starting validator API server")
+		if err := service.ServeAPI(addr); err != nil {
+			logWithCommand.Fatal(err)
+		}
+	}
+
 	wg := new(sync.WaitGroup)
 	wg.Add(1)
 	go service.Start(context.Background(), wg)
 
 	shutdown := make(chan os.Signal, 1)
 	signal.Notify(shutdown, os.Interrupt)
-	<-shutdown
-	service.Stop()
+	select {
+	case <-shutdown:
+		service.Stop()
+	case <-service.Done():
+	}
 	wg.Wait()
+
+	summary := service.Summary()
+	for _, failure := range summary.Failures {
+		logWithCommand.Errorf("block %d failed validation: %s", failure.BlockNumber, failure.Err)
+	}
+	if !summary.Passed() {
+		logWithCommand.Errorf("validation did not pass: %s", &summary)
+		os.Exit(1)
+	}
+	logWithCommand.Infof("validation passed: %s", &summary)
 }
 
 func init() {
 	rootCmd.AddCommand(stateValidatorCmd)
 
+	stateValidatorCmd.PersistentFlags().String("validator-id", "default", "ID under which this validator's checkpoint is stored")
+	stateValidatorCmd.PersistentFlags().Bool("ignore-checkpoint", false, "whether to ignore the stored checkpoint and start from from-block")
 	stateValidatorCmd.PersistentFlags().String("from-block", "1", "block height to initiate state validation")
+	stateValidatorCmd.PersistentFlags().String("to-block", "0", "block height to end state validation at and exit (0 to run indefinitely)")
 	stateValidatorCmd.PersistentFlags().String("trail", "64", "trail of block height to validate")
+	stateValidatorCmd.PersistentFlags().String("workers", "1", "number of blocks to validate concurrently")
+	stateValidatorCmd.PersistentFlags().Bool("all-forks", false, "whether to also validate non-canonical blocks at each height")
+	stateValidatorCmd.PersistentFlags().String("reorg-window", "0", "number of validated heights to check for reorgs (0 to disable)")
 	stateValidatorCmd.PersistentFlags().String("retry-interval", "10s", "retry interval in seconds after validator has caught up to (head-trail) height")
 	stateValidatorCmd.PersistentFlags().Bool("statediff-missing-block", false, "whether to perform a statediffing call on a missing block")
 	stateValidatorCmd.PersistentFlags().String("statediff-timeout", "240s", "statediffing call timeout period (in sec)")
+	stateValidatorCmd.PersistentFlags().String("missing-block-policy", "", "action on a block missing from the index (fail, record-and-skip, fill-and-revalidate)")
+
+	stateValidatorCmd.PersistentFlags().String("failure-policy", "halt", "action on a block failing validation (halt, skip, retry-then-skip)")
+	stateValidatorCmd.PersistentFlags().String("failure-retries", "3", "number of times to retry a failed block with the retry-then-skip policy")
+
+	stateValidatorCmd.PersistentFlags().String("trie-check-interval", "0", "interval in blocks at which to check the whole state trie (0 to disable)")
+	stateValidatorCmd.PersistentFlags().Bool("trie-check-storage", false, "whether periodic state trie checks also check every storage trie")
 
-	stateValidatorCmd.PersistentFlags().String("eth-chain-config", "", "path to json chain config")
-	stateValidatorCmd.PersistentFlags().String("eth-chain-id", "1", "eth chain id")
-	stateValidatorCmd.PersistentFlags().String("eth-http-path", "", "http url for a statediffing node")
+	stateValidatorCmd.PersistentFlags().Bool("server-http", false, "enable the validator API http server")
+	stateValidatorCmd.PersistentFlags().String("server-httpAddr", "127.0.0.1", "validator API http host")
+	stateValidatorCmd.PersistentFlags().String("server-httpPort", "9002", "validator API http port")
 
+	_ = viper.BindPFlag("validate.id", stateValidatorCmd.PersistentFlags().Lookup("validator-id"))
+	_ = viper.BindPFlag("validate.ignoreCheckpoint", stateValidatorCmd.PersistentFlags().Lookup("ignore-checkpoint"))
 	_ = viper.BindPFlag("validate.fromBlock", stateValidatorCmd.PersistentFlags().Lookup("from-block"))
+	_ = viper.BindPFlag("validate.toBlock", stateValidatorCmd.PersistentFlags().Lookup("to-block"))
 	_ = viper.BindPFlag("validate.trail", stateValidatorCmd.PersistentFlags().Lookup("trail"))
+	_ = viper.BindPFlag("validate.workers", stateValidatorCmd.PersistentFlags().Lookup("workers"))
+	_ = viper.BindPFlag("validate.allForks", stateValidatorCmd.PersistentFlags().Lookup("all-forks"))
+	_ = viper.BindPFlag("validate.reorgWindow", stateValidatorCmd.PersistentFlags().Lookup("reorg-window"))
 	_ = viper.BindPFlag("validate.retryInterval", stateValidatorCmd.PersistentFlags().Lookup("retry-interval"))
 	_ = viper.BindPFlag("validate.stateDiffMissingBlock", stateValidatorCmd.PersistentFlags().Lookup("statediff-missing-block"))
 	_ = viper.BindPFlag("validate.stateDiffTimeout", stateValidatorCmd.PersistentFlags().Lookup("statediff-timeout"))
+	_ = viper.BindPFlag("validate.missingBlockPolicy", stateValidatorCmd.PersistentFlags().Lookup("missing-block-policy"))
+
+	_ = viper.BindPFlag("validate.failurePolicy", stateValidatorCmd.PersistentFlags().Lookup("failure-policy"))
+	_ = viper.BindPFlag("validate.failureRetries", stateValidatorCmd.PersistentFlags().Lookup("failure-retries"))
+
+	_ = viper.BindPFlag("validate.trieCheckInterval", stateValidatorCmd.PersistentFlags().Lookup("trie-check-interval"))
+	_ = viper.BindPFlag("validate.trieCheckStorage", stateValidatorCmd.PersistentFlags().Lookup("trie-check-storage"))
 
-	_ = viper.BindPFlag("ethereum.chainConfig", stateValidatorCmd.PersistentFlags().Lookup("eth-chain-config"))
-	_ = viper.BindPFlag("ethereum.chainID", stateValidatorCmd.PersistentFlags().Lookup("eth-chain-id"))
-	_ = viper.BindPFlag("ethereum.httpPath", stateValidatorCmd.PersistentFlags().Lookup("eth-http-path"))
+	_ = viper.BindPFlag("server.http", stateValidatorCmd.PersistentFlags().Lookup("server-http"))
+	_ = viper.BindPFlag("server.httpAddr", stateValidatorCmd.PersistentFlags().Lookup("server-httpAddr"))
+	_ = viper.BindPFlag("server.httpPort", stateValidatorCmd.PersistentFlags().Lookup("server-httpPort"))
 }
 
 func initConfig() {
@@ -1,10 +1,17 @@
 package cmd
 
 import (
+	"context"
+	"fmt"
 	"os"
 
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
+
+	ipldeth "github.com/cerc-io/ipld-eth-server/v5/pkg/eth"
 )
 
 func ParseLogFlags() {
@@ -33,3 +40,21 @@ func ParseLogFlags() {
 	}
 	log.Info("Log level set to ", lvl)
 }
+
+// fetchBlock fetches the block with the given hash if set, otherwise the block at the given height
+func fetchBlock(ctx context.Context, b *ipldeth.Backend, number int64, hash string) (*types.Block, error) {
+	var block *types.Block
+	var err error
+	if hash != "" {
+		block, err = b.BlockByHash(ctx, common.HexToHash(hash))
+	} else {
+		block, err = b.BlockByNumber(ctx, rpc.BlockNumber(number))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if block == nil {
+		return nil, fmt.Errorf("block not found")
+	}
+	return block, nil
+}
@@ -0,0 +1,134 @@
+// VulcanizeDB
+// Copyright © 2023 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/cerc-io/plugeth-statediff/indexer/database/sql/postgres"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/cerc-io/ipld-eth-db-validator/v5/pkg/validator"
+)
+
+var (
+	validateBlockNumber int64
+	validateBlockHash   string
+	validateBlockOutput string
+)
+
+// validateBlockCmd represents the validateBlock command
+var validateBlockCmd = &cobra.Command{
+	Use:   "validateBlock",
+	Short: "Validate a single block and exit",
+	Long: `Usage ./ipld-eth-db-validator validateBlock --config={path to toml config file} --block={block number}
+       ./ipld-eth-db-validator validateBlock --config={path to toml config file} --hash={block hash}`,
+
+	Run: func(cmd *cobra.Command, args []string) {
+		subCommand = cmd.CalledAs()
+		logWithCommand = *log.WithField("SubCommand", subCommand)
+		validateBlock()
+	},
+}
+
+func validateBlock() {
+	if (validateBlockNumber < 0) == (validateBlockHash == "") {
+		logWithCommand.Fatal("exactly one of --block or --hash must be provided")
+	}
+	if validateBlockOutput != "text" && validateBlockOutput != "json" {
+		logWithCommand.Fatalf("invalid output format %q (text, json)", validateBlockOutput)
+	}
+
+	cfg, err := validator.NewEthConfig()
+	if err != nil {
+		logWithCommand.Fatal(err)
+	}
+
+	ctx := context.Background()
+	db, err := postgres.ConnectSQLX(ctx, cfg.DBConfig)
+	if err != nil {
+		logWithCommand.Fatal(err)
+	}
+	defer db.Close()
+
+	api, err := validator.EthAPI(ctx, db, cfg.ChainConfig)
+	if err != nil {
+		logWithCommand.Fatal(err)
+	}
+	defer api.B.Close()
+
+	block, err := fetchBlock(ctx, api.B, validateBlockNumber, validateBlockHash)
+	if err != nil {
+		logWithCommand.Fatal(err)
+	}
+
+	result := validator.CheckBlock(ctx, db, api.B, cfg.Client, block)
+	if validateBlockOutput == "json" {
+		out, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			logWithCommand.Fatal(err)
+		}
+		fmt.Println(string(out))
+	} else {
+		printBlockResult(result, validator.ReferentialIntegrityChecks(cfg.ChainConfig, api.B.IpldTrieStateDatabase))
+	}
+
+	if !result.Passed() {
+		// deferred cleanup is skipped by os.Exit
+		api.B.Close()
+		db.Close()
+		os.Exit(1)
+	}
+}
+
+// printBlockResult prints a human-readable report of each of the given checks in the result
+func printBlockResult(result *validator.BlockResult, checks []validator.ReferentialIntegrityCheck) {
+	fmt.Printf("block %d (%s)\n", result.BlockNumber, result.BlockHash.Hex())
+	if result.StateRootOK {
+		fmt.Println("  block replay:        ok")
+	} else {
+		fmt.Printf("  block replay:        FAILED: %s\n", result.Err)
+	}
+	for _, check := range checks {
+		passed, ok := result.RefIntegrity[check.Name]
+		switch {
+		case !ok:
+			fmt.Printf("  %-20s not run\n", check.Name+":")
+		case passed:
+			fmt.Printf("  %-20s ok\n", check.Name+":")
+		default:
+			fmt.Printf("  %-20s FAILED\n", check.Name+":")
+		}
+	}
+	if result.Passed() {
+		fmt.Printf("PASSED in %s\n", result.Duration)
+	} else {
+		fmt.Printf("FAILED in %s: %s\n", result.Duration, result.Err)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(validateBlockCmd)
+
+	validateBlockCmd.Flags().Int64Var(&validateBlockNumber, "block", -1, "number of the block to validate")
+	validateBlockCmd.Flags().StringVar(&validateBlockHash, "hash", "", "hash of the block to validate")
+	validateBlockCmd.Flags().StringVar(&validateBlockOutput, "output", "text", "output format (text, json)")
+}
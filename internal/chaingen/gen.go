@@ -6,6 +6,7 @@ import (
 	"math/big"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/consensus/ethash"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/types"
@@ -19,6 +20,8 @@ type GenContext struct {
 	ChainConfig *params.ChainConfig
 	GenFuncs    []func(int, *core.BlockGen)
 	DB          ethdb.Database
+	// Consensus engine used to generate the chain; defaults to the ethash faker
+	Engine consensus.Engine
 
 	Keys      map[common.Address]*ecdsa.PrivateKey
 	Contracts map[string]*ContractSpec
@@ -63,10 +66,14 @@ func (gen *GenContext) generate(i int, block *core.BlockGen) {
 // MakeChain creates a chain of n blocks starting at and including the genesis block.
 // the returned hash chain is ordered head->parent.
 func (gen *GenContext) MakeChain(n int) ([]*types.Block, []types.Receipts, *core.BlockChain) {
+	engine := gen.Engine
+	if engine == nil {
+		engine = ethash.NewFaker()
+	}
 	blocks, receipts := core.GenerateChain(
-		gen.ChainConfig, gen.Genesis, ethash.NewFaker(), gen.DB, n, gen.generate,
+		gen.ChainConfig, gen.Genesis, engine, gen.DB, n, gen.generate,
 	)
-	chain, err := core.NewBlockChain(gen.DB, nil, nil, nil, ethash.NewFaker(), vm.Config{}, nil, nil)
+	chain, err := core.NewBlockChain(gen.DB, nil, nil, nil, engine, vm.Config{}, nil, nil)
 	if err != nil {
 		panic(err)
 	}
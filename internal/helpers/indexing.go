@@ -34,6 +34,7 @@ type IndexChainParams struct {
 	StateCache state.Database
 
 	StateDiffParams statediff.Params
+	// If nil, the total difficulty of each block is summed from the first block
 	TotalDifficulty *big.Int
 	// Whether to skip indexing state nodes (state_cids, storage_cids)
 	SkipStateNodes bool
@@ -43,6 +44,7 @@ type IndexChainParams struct {
 
 func IndexChain(indexer interfaces.StateDiffIndexer, params IndexChainParams) error {
 	builder := statediff.NewBuilder(adapt.GethStateView(params.StateCache))
+	totalDifficulty := new(big.Int)
 	// iterate over the blocks, generating statediff payloads, and transforming the data into Postgres
 	for i, block := range params.Blocks {
 		var args statediff.Args
@@ -68,7 +70,12 @@ func IndexChain(indexer interfaces.StateDiffIndexer, params IndexChainParams) er
 		if err != nil {
 			return fmt.Errorf("failed to build diff (block %d): %w", block.Number(), err)
 		}
-		tx, err := indexer.PushBlock(block, rcts, params.TotalDifficulty)
+		td := params.TotalDifficulty
+		if td == nil {
+			totalDifficulty.Add(totalDifficulty, block.Difficulty())
+			td = new(big.Int).Set(totalDifficulty)
+		}
+		tx, err := indexer.PushBlock(block, rcts, td)
 		if err != nil {
 			return fmt.Errorf("failed to index block (block %d): %w", block.Number(), err)
 		}
@@ -31,6 +31,9 @@ const (
 var (
 	metrics            bool
 	lastValidatedBlock prometheus.Gauge
+	validationFailures prometheus.Counter
+	reorgsDetected     prometheus.Counter
+	lastCompleteTrie   prometheus.Gauge
 )
 
 func Init() {
@@ -42,6 +45,24 @@ func Init() {
 		Name:      "last_validated_block",
 		Help:      "Last validated block number",
 	})
+	validationFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: statsSubsystem,
+		Name:      "validation_failures",
+		Help:      "Number of blocks which failed validation",
+	})
+	reorgsDetected = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: statsSubsystem,
+		Name:      "reorgs_detected",
+		Help:      "Number of validated heights whose canonical block has since changed",
+	})
+	lastCompleteTrie = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: statsSubsystem,
+		Name:      "last_complete_state_trie",
+		Help:      "Last block number at which the whole state trie was found to be indexed",
+	})
 }
 
 // RegisterDBCollector create metric collector for given connection
@@ -57,3 +78,24 @@ func SetLastValidatedBlock(blockNumber float64) {
 		lastValidatedBlock.Set(blockNumber)
 	}
 }
+
+// IncValidationFailures increments the number of blocks which failed validation
+func IncValidationFailures() {
+	if metrics {
+		validationFailures.Inc()
+	}
+}
+
+// IncReorgsDetected increments the number of reorgs detected below the validated height
+func IncReorgsDetected() {
+	if metrics {
+		reorgsDetected.Inc()
+	}
+}
+
+// SetLastCompleteStateTrie sets the last block number at which the whole state trie was indexed
+func SetLastCompleteStateTrie(blockNumber float64) {
+	if metrics {
+		lastCompleteTrie.Set(blockNumber)
+	}
+}
@@ -0,0 +1,150 @@
+// VulcanizeDB
+// Copyright © 2023 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package validator
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// APIName is the namespace of the validator's JSON-RPC API
+const APIName = "validator"
+
+// maxRangeSize limits the number of blocks that can be validated in one API request
+const maxRangeSize = 1000
+
+// status tracks the progress of the service for reporting
+type status struct {
+	sync.RWMutex
+	nextBlock, head uint64
+	lastFailure     *BlockFailure
+}
+
+func (st *status) setNextBlock(blockNum uint64) {
+	st.Lock()
+	defer st.Unlock()
+	st.nextBlock = blockNum
+}
+
+func (st *status) setHead(blockNum uint64) {
+	st.Lock()
+	defer st.Unlock()
+	st.head = blockNum
+}
+
+func (st *status) setLastFailure(blockNum uint64, err error) {
+	st.Lock()
+	defer st.Unlock()
+	st.lastFailure = &BlockFailure{blockNum, err}
+}
+
+// Status describes the current state of the validator service
+type Status struct {
+	NextBlock uint64 `json:"nextBlock"`
+	Head      uint64 `json:"head"`
+	// Number of blocks between the next block to validate and the head
+	Lag              uint64 `json:"lag"`
+	LastError        string `json:"lastError,omitempty"`
+	LastErrorAtBlock uint64 `json:"lastErrorAtBlock,omitempty"`
+}
+
+// Status returns the current state of the service
+func (s *Service) Status() Status {
+	s.status.RLock()
+	defer s.status.RUnlock()
+
+	st := Status{
+		NextBlock: s.status.nextBlock,
+		Head:      s.status.head,
+	}
+	if st.Head >= st.NextBlock {
+		st.Lag = st.Head - st.NextBlock
+	}
+	if failure := s.status.lastFailure; failure != nil {
+		st.LastError = failure.Err.Error()
+		st.LastErrorAtBlock = failure.BlockNumber
+	}
+	return st
+}
+
+// PublicValidatorAPI provides an API to inspect the validator service and validate blocks on demand
+type PublicValidatorAPI struct {
+	service *Service
+}
+
+// NewPublicValidatorAPI creates a new PublicValidatorAPI for the given service
+func NewPublicValidatorAPI(service *Service) *PublicValidatorAPI {
+	return &PublicValidatorAPI{service: service}
+}
+
+// Status returns the current state of the validator
+func (api *PublicValidatorAPI) Status() Status {
+	return api.service.Status()
+}
+
+// ValidateBlockByNumber validates the block(s) at the given height and returns the results
+func (api *PublicValidatorAPI) ValidateBlockByNumber(ctx context.Context, number uint64) ([]*BlockResult, error) {
+	results, err := api.service.validateHeight(ctx, api.service.api, number)
+	if len(results) == 0 && err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// ValidateBlockByHash validates the block with the given hash and returns the result, along with
+// the error if it fails
+func (api *PublicValidatorAPI) ValidateBlockByHash(ctx context.Context, hash common.Hash) (*BlockResult, error) {
+	block, err := api.service.api.B.BlockByHash(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	if block == nil {
+		return nil, fmt.Errorf("block %s not found", hash)
+	}
+	results, err := api.service.validateBlocks(ctx, api.service.api, block.NumberU64(), []*types.Block{block})
+	return results[0], err
+}
+
+// CheckStateTrie checks that the whole state trie of the canonical block at the given height, and
+// optionally every storage trie, is indexed, and returns the result
+func (api *PublicValidatorAPI) CheckStateTrie(ctx context.Context, number uint64, storage bool) (*TrieCheckResult, error) {
+	return api.service.checkStateTrie(ctx, api.service.api, number, storage)
+}
+
+// ValidateRange validates the blocks at each height from start to end inclusive, and returns the results
+func (api *PublicValidatorAPI) ValidateRange(ctx context.Context, start, end uint64) ([]*BlockResult, error) {
+	if end < start {
+		return nil, fmt.Errorf("invalid range %d-%d", start, end)
+	}
+	if end-start+1 > maxRangeSize {
+		return nil, fmt.Errorf("range %d-%d exceeds maximum size of %d blocks", start, end, maxRangeSize)
+	}
+
+	var results []*BlockResult
+	for height := start; height <= end; height++ {
+		res, err := api.service.validateHeight(ctx, api.service.api, height)
+		if len(res) == 0 && err != nil {
+			return nil, fmt.Errorf("failed to validate block %d: %w", height, err)
+		}
+		results = append(results, res...)
+	}
+	return results, nil
+}
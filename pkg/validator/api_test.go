@@ -0,0 +1,150 @@
+package validator_test
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/cerc-io/ipld-eth-db-validator/v5/pkg/validator"
+)
+
+func TestValidatorAPI(t *testing.T) {
+	db := setupStateValidator(t)
+	const validatorID = "test-api"
+	clearValidator(t, db, validatorID)
+
+	// The service waits for a height beyond the head, keeping its backend open for the API
+	const nextBlock = 100
+	service, err := validator.NewService(serviceConfig(validatorID, nextBlock), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+	if err := service.ServeAPI(addr); err != nil {
+		t.Fatal(err)
+	}
+	wg := new(sync.WaitGroup)
+	wg.Add(1)
+	go service.Start(context.Background(), wg)
+	defer func() {
+		service.Stop()
+		wg.Wait()
+	}()
+
+	api := validator.NewPublicValidatorAPI(service)
+	ctx := context.Background()
+	blockHash := func(t *testing.T, blockNum uint64) common.Hash {
+		var hash string
+		if err := db.Get(&hash, `SELECT block_hash FROM eth.header_cids WHERE block_number = $1`, blockNum); err != nil {
+			t.Fatal(err)
+		}
+		return common.HexToHash(hash)
+	}
+
+	t.Run("Status", func(t *testing.T) {
+		var status validator.Status
+		for deadline := time.Now().Add(10 * time.Second); status.NextBlock != nextBlock || status.Head != chainLength; {
+			if time.Now().After(deadline) {
+				t.Fatalf("expected next block %d and head %d, got %+v", nextBlock, chainLength, status)
+			}
+			res, err := http.Get("http://" + addr + "/status")
+			if err != nil {
+				time.Sleep(100 * time.Millisecond)
+				continue
+			}
+			err = json.NewDecoder(res.Body).Decode(&status)
+			res.Body.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+
+	t.Run("ValidateBlockByNumber", func(t *testing.T) {
+		results, err := api.ValidateBlockByNumber(ctx, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 1 || !results[0].Passed() || results[0].BlockHash != blockHash(t, 3) {
+			t.Fatalf("expected block 3 to pass, got %+v", results)
+		}
+		var recorded int
+		if err := db.Get(&recorded, `SELECT count(*) FROM validator.block_results WHERE validator_id = $1 AND block_number = 3`,
+			validatorID); err != nil {
+			t.Fatal(err)
+		}
+		if recorded != 1 {
+			t.Fatalf("expected the result to be recorded once, got %d", recorded)
+		}
+	})
+
+	t.Run("ValidateBlockByHash", func(t *testing.T) {
+		result, err := api.ValidateBlockByHash(ctx, blockHash(t, 4))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.Passed() || result.BlockNumber != 4 {
+			t.Fatalf("expected block 4 to pass, got %+v", result)
+		}
+
+		if _, err := api.ValidateBlockByHash(ctx, common.HexToHash("0x1")); err == nil {
+			t.Fatal("expected an error for an unknown block")
+		}
+	})
+
+	t.Run("ValidateRange", func(t *testing.T) {
+		results, err := api.ValidateRange(ctx, 5, 6)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 2 || results[0].BlockNumber != 5 || results[1].BlockNumber != 6 {
+			t.Fatalf("expected results for blocks 5 and 6, got %+v", results)
+		}
+		for _, result := range results {
+			if !result.Passed() {
+				t.Fatalf("expected block %d to pass, got %v", result.BlockNumber, result.Err)
+			}
+		}
+
+		if _, err := api.ValidateRange(ctx, 6, 5); err == nil {
+			t.Fatal("expected an error for an inverted range")
+		}
+		if _, err := api.ValidateRange(ctx, 1, 1001); err == nil {
+			t.Fatal("expected an error for a range over the maximum size")
+		}
+	})
+
+	t.Run("Failing block", func(t *testing.T) {
+		if _, err := db.Exec(`UPDATE eth.transaction_cids SET src = $1 WHERE block_number = 5`,
+			common.HexToAddress("0x1").Hex()); err != nil {
+			t.Fatal(err)
+		}
+
+		result, err := api.ValidateBlockByHash(ctx, blockHash(t, 5))
+		if err == nil {
+			t.Fatal("expected an error for a failing block")
+		}
+		if result.Passed() {
+			t.Fatal("expected the result to fail")
+		}
+
+		results, err := api.ValidateBlockByNumber(ctx, 5)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 1 || results[0].Passed() {
+			t.Fatalf("expected a failed result for block 5, got %+v", results)
+		}
+	})
+}
@@ -0,0 +1,89 @@
+// VulcanizeDB
+// Copyright © 2023 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package validator
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Tables owned by the validator are kept in their own schema, alongside the indexed data
+var validatorSchema = []string{
+	`CREATE SCHEMA IF NOT EXISTS validator`,
+	`CREATE TABLE IF NOT EXISTS validator.checkpoints (
+		validator_id TEXT PRIMARY KEY,
+		block_number BIGINT NOT NULL,
+		updated_at   TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+	)`,
+	`CREATE TABLE IF NOT EXISTS validator.block_results (
+		id                BIGSERIAL PRIMARY KEY,
+		validator_id      TEXT NOT NULL,
+		block_number      BIGINT NOT NULL,
+		block_hash        VARCHAR(66) NOT NULL,
+		state_root_ok     BOOLEAN NOT NULL,
+		ref_integrity     JSONB NOT NULL,
+		passed            BOOLEAN NOT NULL,
+		duration_ms       BIGINT NOT NULL,
+		error             TEXT,
+		validator_version TEXT NOT NULL,
+		validated_at      TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+	)`,
+	`CREATE INDEX IF NOT EXISTS block_results_block_idx ON validator.block_results (block_number, block_hash)`,
+}
+
+const (
+	getCheckpointPgStr = `SELECT block_number FROM validator.checkpoints WHERE validator_id = $1`
+
+	setCheckpointPgStr = `INSERT INTO validator.checkpoints (validator_id, block_number)
+						VALUES ($1, $2)
+						ON CONFLICT (validator_id) DO UPDATE
+						SET block_number = EXCLUDED.block_number, updated_at = now()`
+)
+
+// createValidatorTables creates the tables owned by the validator if they don't already exist
+func createValidatorTables(db *sqlx.DB) error {
+	for _, schema := range [][]string{validatorSchema, trieCheckSchema} {
+		for _, stm := range schema {
+			if _, err := db.Exec(stm); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// loadCheckpoint returns the last height up to which all blocks were validated by the given
+// validator, and whether a checkpoint exists for it
+func loadCheckpoint(db *sqlx.DB, validatorID string) (uint64, bool, error) {
+	var blockNum uint64
+	err := db.Get(&blockNum, getCheckpointPgStr, validatorID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return blockNum, true, nil
+}
+
+// saveCheckpoint records the last height up to which all blocks were validated by the given validator
+func saveCheckpoint(db *sqlx.DB, validatorID string, blockNum uint64) error {
+	_, err := db.Exec(setCheckpointPgStr, validatorID, blockNum)
+	return err
+}
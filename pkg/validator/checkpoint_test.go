@@ -0,0 +1,138 @@
+package validator_test
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/cerc-io/ipld-eth-db-validator/v5/pkg/validator"
+)
+
+const (
+	testValidatorID    = "test"
+	getCheckpointPgStr = `SELECT block_number FROM validator.checkpoints WHERE validator_id = $1`
+	setCheckpointPgStr = `INSERT INTO validator.checkpoints (validator_id, block_number) VALUES ($1, $2)
+						ON CONFLICT (validator_id) DO UPDATE SET block_number = EXCLUDED.block_number`
+)
+
+// runRange runs a validator service over the given range, and returns its summary
+func runRange(t *testing.T, from, to uint64) validator.Summary {
+	cfg := serviceConfig(testValidatorID, from)
+	cfg.ToBlock = to
+	cfg.FailurePolicy = validator.FailurePolicySkip
+	service, _ := runService(t, cfg, 0)
+	return service.Summary()
+}
+
+func getCheckpoint(t *testing.T, db *sqlx.DB, validatorID string) (uint64, bool) {
+	var checkpoints []uint64
+	if err := db.Select(&checkpoints, getCheckpointPgStr, validatorID); err != nil {
+		t.Fatal(err)
+	}
+	if len(checkpoints) == 0 {
+		return 0, false
+	}
+	return checkpoints[0], true
+}
+
+func setCheckpoint(t *testing.T, db *sqlx.DB, validatorID string, checkpoint uint64) {
+	if _, err := db.Exec(setCheckpointPgStr, validatorID, checkpoint); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// The test ranges start above the heights with non-canonical mock blocks, whose transactions
+// don't match the test chain's config
+const firstCheckedBlock = 3
+
+// A bounded range is validated in full and leaves the checkpoint alone, even when a validator with
+// the same ID has a checkpoint inside or beyond the range
+func TestCheckpointWithRange(t *testing.T) {
+	db := setupStateValidator(t)
+	clearValidator(t, db, testValidatorID)
+
+	// The first run creates the validator tables
+	summary := runRange(t, firstCheckedBlock, firstCheckedBlock+2)
+	if !summary.Passed() || summary.Validated != 3 {
+		t.Fatalf("expected 3 blocks to be validated, got %s", &summary)
+	}
+	if checkpoint, ok := getCheckpoint(t, db, testValidatorID); ok {
+		t.Fatalf("expected no checkpoint to be saved by a range run, got %d", checkpoint)
+	}
+
+	for _, checkpoint := range []uint64{5, 6, 10} {
+		setCheckpoint(t, db, testValidatorID, checkpoint)
+
+		summary := runRange(t, 4, 6)
+		if summary.FromBlock != 4 {
+			t.Fatalf("expected summary to start at block 4, got %d", summary.FromBlock)
+		}
+		if !summary.Passed() || summary.Validated != 3 || summary.LastValidated != 6 {
+			t.Fatalf("checkpoint %d: expected blocks 4-6 to be validated, got %s", checkpoint, &summary)
+		}
+		if saved, _ := getCheckpoint(t, db, testValidatorID); saved != checkpoint {
+			t.Fatalf("expected checkpoint to remain at %d, got %d", checkpoint, saved)
+		}
+	}
+}
+
+// An unbounded run resumes after a saved checkpoint, unless told to ignore it
+func TestCheckpointResume(t *testing.T) {
+	db := setupStateValidator(t)
+
+	t.Run("Resume", func(t *testing.T) {
+		const validatorID = "test-resume"
+		clearValidator(t, db, validatorID)
+		// Create the validator tables before saving the checkpoint
+		runService(t, serviceConfig(validatorID, chainLength), chainLength)
+		setCheckpoint(t, db, validatorID, 7)
+
+		_, validated := runService(t, serviceConfig(validatorID, firstCheckedBlock), chainLength)
+		assertHeights(t, []uint64{8, 9, 10}, validated)
+		if checkpoint, _ := getCheckpoint(t, db, validatorID); checkpoint != chainLength {
+			t.Fatalf("expected checkpoint at %d, got %d", chainLength, checkpoint)
+		}
+	})
+
+	t.Run("Ignore checkpoint", func(t *testing.T) {
+		const validatorID = "test-ignore-checkpoint"
+		clearValidator(t, db, validatorID)
+		runService(t, serviceConfig(validatorID, chainLength), chainLength)
+		setCheckpoint(t, db, validatorID, 7)
+
+		cfg := serviceConfig(validatorID, firstCheckedBlock)
+		cfg.IgnoreCheckpoint = true
+		_, validated := runService(t, cfg, chainLength)
+		assertHeights(t, []uint64{3, 4, 5, 6, 7, 8, 9, 10}, validated)
+	})
+}
+
+// Under the skip policy, a failed block is recorded and the checkpoint moves past it
+func TestCheckpointPastSkippedBlock(t *testing.T) {
+	db := setupStateValidator(t)
+	const validatorID = "test-skip-checkpoint"
+	clearValidator(t, db, validatorID)
+
+	const failingBlock = 5
+	if _, err := db.Exec(`UPDATE eth.transaction_cids SET src = $1 WHERE block_number = $2`,
+		common.HexToAddress("0x1").Hex(), failingBlock); err != nil {
+		t.Fatal(err)
+	}
+	cfg := serviceConfig(validatorID, firstCheckedBlock)
+	cfg.FailurePolicy = validator.FailurePolicySkip
+	_, validated := runService(t, cfg, chainLength)
+
+	assertHeights(t, []uint64{3, 4, 6, 7, 8, 9, 10}, validated)
+	if checkpoint, _ := getCheckpoint(t, db, validatorID); checkpoint != chainLength {
+		t.Fatalf("expected checkpoint at %d, got %d", chainLength, checkpoint)
+	}
+	var passed []bool
+	if err := db.Select(&passed, `SELECT passed FROM validator.block_results WHERE validator_id = $1 AND block_number = $2`,
+		validatorID, failingBlock); err != nil {
+		t.Fatal(err)
+	}
+	if len(passed) != 1 || passed[0] {
+		t.Fatalf("expected a failed result for block %d, got %v", failingBlock, passed)
+	}
+}
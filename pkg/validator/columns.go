@@ -0,0 +1,197 @@
+// VulcanizeDB
+// Copyright © 2023 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package validator
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/jmoiron/sqlx"
+)
+
+type headerColumnsRow struct {
+	BlockHash   string         `db:"block_hash"`
+	ParentHash  string         `db:"parent_hash"`
+	TD          string         `db:"td"`
+	StateRoot   string         `db:"state_root"`
+	TxRoot      string         `db:"tx_root"`
+	ReceiptRoot string         `db:"receipt_root"`
+	UnclesHash  string         `db:"uncles_hash"`
+	Bloom       []byte         `db:"bloom"`
+	Timestamp   uint64         `db:"timestamp"`
+	Coinbase    string         `db:"coinbase"`
+	Data        []byte         `db:"data"`
+	ParentTD    sql.NullString `db:"parent_td"`
+}
+
+// ValidateHeaderColumns decodes the header IPLD of each eth.header_cids row at the given height,
+// and checks that the row's columns match the decoded header. The total difficulty is checked
+// against the parent's if the parent header is indexed.
+func ValidateHeaderColumns(tx *sqlx.Tx, blockNumber uint64) error {
+	var rows []headerColumnsRow
+	if err := tx.Select(&rows, HeaderCIDsColumns, blockNumber); err != nil {
+		return err
+	}
+
+	var mismatches []ColumnMismatch
+	for _, row := range rows {
+		var header types.Header
+		if err := rlp.DecodeBytes(row.Data, &header); err != nil {
+			return fmt.Errorf("failed to decode header %s at block %d: %w", row.BlockHash, blockNumber, err)
+		}
+		mismatch := func(column string, indexed, decoded interface{}) {
+			mismatches = append(mismatches, ColumnMismatch{row.BlockHash, column, indexed, decoded})
+		}
+
+		if hash := header.Hash(); hash != common.HexToHash(row.BlockHash) {
+			mismatch("block_hash", row.BlockHash, hash)
+		}
+		if header.Number.Uint64() != blockNumber {
+			mismatch("block_number", blockNumber, header.Number)
+		}
+		if header.ParentHash != common.HexToHash(row.ParentHash) {
+			mismatch("parent_hash", row.ParentHash, header.ParentHash)
+		}
+		if header.Root != common.HexToHash(row.StateRoot) {
+			mismatch("state_root", row.StateRoot, header.Root)
+		}
+		if header.TxHash != common.HexToHash(row.TxRoot) {
+			mismatch("tx_root", row.TxRoot, header.TxHash)
+		}
+		if header.ReceiptHash != common.HexToHash(row.ReceiptRoot) {
+			mismatch("receipt_root", row.ReceiptRoot, header.ReceiptHash)
+		}
+		if header.UncleHash != common.HexToHash(row.UnclesHash) {
+			mismatch("uncles_hash", row.UnclesHash, header.UncleHash)
+		}
+		if !bytes.Equal(header.Bloom.Bytes(), row.Bloom) {
+			mismatch("bloom", hexutil.Bytes(row.Bloom), hexutil.Bytes(header.Bloom.Bytes()))
+		}
+		if header.Time != row.Timestamp {
+			mismatch("timestamp", row.Timestamp, header.Time)
+		}
+		if header.Coinbase != common.HexToAddress(row.Coinbase) {
+			mismatch("coinbase", row.Coinbase, header.Coinbase)
+		}
+
+		// The total difficulty is the parent's plus this block's difficulty
+		var expectedTD *big.Int
+		if blockNumber == 0 {
+			expectedTD = header.Difficulty
+		} else if row.ParentTD.Valid {
+			parentTD, ok := new(big.Int).SetString(row.ParentTD.String, 10)
+			if !ok {
+				return fmt.Errorf("invalid td %q for parent of header %s", row.ParentTD.String, row.BlockHash)
+			}
+			expectedTD = parentTD.Add(parentTD, header.Difficulty)
+		}
+		if expectedTD != nil && expectedTD.String() != row.TD {
+			mismatch("td", row.TD, expectedTD)
+		}
+	}
+	if len(mismatches) != 0 {
+		return &ColumnMismatchError{blockNumber, "eth.header_cids", mismatches}
+	}
+	return nil
+}
+
+type headerTxRootRow struct {
+	BlockHash string `db:"block_hash"`
+	TxRoot    string `db:"tx_root"`
+}
+
+type transactionColumnsRow struct {
+	HeaderID string `db:"header_id"`
+	TxHash   string `db:"tx_hash"`
+	Index    int    `db:"index"`
+	Src      string `db:"src"`
+	Dst      string `db:"dst"`
+	Value    string `db:"value"`
+	TxType   uint8  `db:"tx_type"`
+	Data     []byte `db:"data"`
+}
+
+// ValidateTransactionColumns decodes the transaction IPLD of each eth.transaction_cids row at the
+// given height, and checks that the row's columns match the decoded transaction. It also checks
+// that the transactions indexed for each header, in order of index, hash to its transactions root.
+// Senders are recovered with the signer of the given chain config at the given height.
+func ValidateTransactionColumns(tx *sqlx.Tx, config *params.ChainConfig, blockNumber uint64) error {
+	var headers []headerTxRootRow
+	if err := tx.Select(&headers, HeaderCIDsTxRoots, blockNumber); err != nil {
+		return err
+	}
+	var rows []transactionColumnsRow
+	if err := tx.Select(&rows, TransactionCIDsColumns, blockNumber); err != nil {
+		return err
+	}
+
+	signer := types.MakeSigner(config, new(big.Int).SetUint64(blockNumber))
+	var mismatches []ColumnMismatch
+	txs := make(map[string]types.Transactions)
+	for _, row := range rows {
+		mismatch := func(column string, indexed, decoded interface{}) {
+			mismatches = append(mismatches, ColumnMismatch{row.TxHash, column, indexed, decoded})
+		}
+
+		trx := new(types.Transaction)
+		if err := trx.UnmarshalBinary(row.Data); err != nil {
+			return fmt.Errorf("failed to decode transaction %s at block %d: %w", row.TxHash, blockNumber, err)
+		}
+		if index := len(txs[row.HeaderID]); row.Index != index {
+			mismatch("index", row.Index, index)
+		}
+		txs[row.HeaderID] = append(txs[row.HeaderID], trx)
+
+		if hash := trx.Hash(); hash != common.HexToHash(row.TxHash) {
+			mismatch("tx_hash", row.TxHash, hash)
+		}
+		if trx.Type() != row.TxType {
+			mismatch("tx_type", row.TxType, trx.Type())
+		}
+		if trx.Value().String() != row.Value {
+			mismatch("value", row.Value, trx.Value())
+		}
+		if to := trx.To(); (to == nil && row.Dst != "") || (to != nil && *to != common.HexToAddress(row.Dst)) {
+			mismatch("dst", row.Dst, to)
+		}
+		// A sender which can't be recovered for this chain, e.g. due to a foreign chain ID, is a mismatch
+		if from, err := types.Sender(signer, trx); err != nil {
+			mismatch("src", row.Src, err)
+		} else if from != common.HexToAddress(row.Src) {
+			mismatch("src", row.Src, from)
+		}
+	}
+
+	for _, header := range headers {
+		txRoot := types.DeriveSha(txs[header.BlockHash], trie.NewStackTrie(nil))
+		if txRoot != common.HexToHash(header.TxRoot) {
+			mismatches = append(mismatches, ColumnMismatch{header.BlockHash, "tx_root", header.TxRoot, txRoot})
+		}
+	}
+	if len(mismatches) != 0 {
+		return &ColumnMismatchError{blockNumber, "eth.transaction_cids", mismatches}
+	}
+	return nil
+}
@@ -31,28 +31,53 @@ type Config struct {
 	DBConfig postgres.Config
 	DBStats  bool
 
+	// Identifies this validator's checkpoint
+	ValidatorID      string
+	IgnoreCheckpoint bool
+
 	ChainConfig *params.ChainConfig
 	// Used to trigger writing state diffs for gaps in the index
 	Client                *rpc.Client
 	FromBlock, Trail      uint64
+	ToBlock               uint64 // zero to validate indefinitely
+	Workers               uint64
+	AllForks              bool
+	ReorgWindow           uint64
 	RetryInterval         time.Duration
-	StateDiffMissingBlock bool
+	StateDiffMissingBlock bool // deprecated: if validate.missingBlockPolicy is unset, fill missing blocks
 	StateDiffTimeout      time.Duration
+	MissingBlockPolicy    MissingBlockPolicy
+	FailurePolicy         FailurePolicy
+	FailureRetries        uint
+	TrieCheckInterval     uint64 // zero to disable periodic state trie checks
+	TrieCheckStorage      bool
 }
 
+// NewConfig reads the config of the validator service
 func NewConfig() (*Config, error) {
-	cfg := new(Config)
-	err := cfg.setupDB()
+	cfg, err := NewEthConfig()
 	if err != nil {
 		return nil, err
 	}
 
-	err = cfg.setupEth()
+	err = cfg.setupValidator()
 	if err != nil {
 		return nil, err
 	}
 
-	err = cfg.setupValidator()
+	return cfg, nil
+}
+
+// NewEthConfig reads only the database and ethereum config, for commands which check the index
+// without running the validator service
+func NewEthConfig() (*Config, error) {
+	cfg := new(Config)
+	err := cfg.setupDB()
+	if err != nil {
+		return nil, err
+	}
+
+	err = cfg.setupEth()
 	if err != nil {
 		return nil, err
 	}
@@ -103,17 +128,57 @@ func (c *Config) setupEth() error {
 
 func (c *Config) setupValidator() error {
 	var err error
+	c.ValidatorID = viper.GetString("validate.id")
+	if c.ValidatorID == "" {
+		return fmt.Errorf("validator ID cannot be empty")
+	}
+	c.IgnoreCheckpoint = viper.GetBool("validate.ignoreCheckpoint")
+
 	c.FromBlock = viper.GetUint64("validate.fromBlock")
 	if c.FromBlock < 1 {
 		return fmt.Errorf("starting block height cannot be less than 1")
 	}
+	c.ToBlock = viper.GetUint64("validate.toBlock")
+	if c.ToBlock != 0 && c.ToBlock < c.FromBlock {
+		return fmt.Errorf("ending block height cannot be less than starting block height")
+	}
 
 	c.Trail = viper.GetUint64("validate.trail")
+	c.Workers = viper.GetUint64("validate.workers")
+	if c.Workers < 1 {
+		c.Workers = 1
+	}
+	c.AllForks = viper.GetBool("validate.allForks")
+	c.ReorgWindow = viper.GetUint64("validate.reorgWindow")
 	c.RetryInterval = viper.GetDuration("validate.retryInterval")
 	c.StateDiffMissingBlock = viper.GetBool("validate.stateDiffMissingBlock")
-	if c.StateDiffMissingBlock {
-		c.StateDiffTimeout = viper.GetDuration("validate.stateDiffTimeout")
+	c.StateDiffTimeout = viper.GetDuration("validate.stateDiffTimeout")
+	if policy := viper.GetString("validate.missingBlockPolicy"); policy != "" {
+		c.MissingBlockPolicy, err = ParseMissingBlockPolicy(policy)
+		if err != nil {
+			return err
+		}
+	}
+	if c.MissingBlockPolicy == "" {
+		c.MissingBlockPolicy = MissingBlockPolicyRecordAndSkip
+		if c.StateDiffMissingBlock {
+			c.MissingBlockPolicy = MissingBlockPolicyFillAndRevalidate
+		}
+	}
+	if c.MissingBlockPolicy == MissingBlockPolicyFillAndRevalidate && c.Client == nil {
+		return fmt.Errorf("missing block policy %s requires ethereum.httpPath", c.MissingBlockPolicy)
+	}
+
+	c.FailurePolicy = FailurePolicyHalt
+	if policy := viper.GetString("validate.failurePolicy"); policy != "" {
+		c.FailurePolicy, err = ParseFailurePolicy(policy)
+		if err != nil {
+			return err
+		}
 	}
+	c.FailureRetries = viper.GetUint("validate.failureRetries")
+	c.TrieCheckInterval = viper.GetUint64("validate.trieCheckInterval")
+	c.TrieCheckStorage = viper.GetBool("validate.trieCheckStorage")
 
 	return err
 }
@@ -1,9 +1,15 @@
 package validator
 
 import (
+	"errors"
 	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
 )
 
+var errStopped = errors.New("validator service stopped")
+
 type ChainNotSyncedError struct {
 	Head uint64
 }
@@ -11,3 +17,100 @@ type ChainNotSyncedError struct {
 func (e *ChainNotSyncedError) Error() string {
 	return fmt.Sprintf("chain not synced (current head: %d)", e.Head)
 }
+
+// HeaderMismatchError is returned when a value derived by replaying a block does not match
+// the corresponding field of the indexed header
+type HeaderMismatchError struct {
+	BlockNumber uint64
+	Field       string
+	Expected    interface{}
+	Actual      interface{}
+}
+
+func (e *HeaderMismatchError) Error() string {
+	return fmt.Sprintf("%s does not match at block %d (header: %v, replay: %v)",
+		e.Field, e.BlockNumber, e.Expected, e.Actual)
+}
+
+// ReceiptsMismatchError is returned when the receipts indexed for a block don't match the receipts
+// produced by replaying it
+type ReceiptsMismatchError struct {
+	BlockNumber uint64
+	// Number of receipts and the root derived from them, as indexed and from the replay
+	IndexedCount, ReplayCount int
+	IndexedRoot, ReplayRoot   common.Hash
+}
+
+func (e *ReceiptsMismatchError) Error() string {
+	return fmt.Sprintf("indexed receipts do not match replay at block %d (indexed: %d receipts with root %s, replay: %d receipts with root %s)",
+		e.BlockNumber, e.IndexedCount, e.IndexedRoot, e.ReplayCount, e.ReplayRoot)
+}
+
+// MissingWithdrawalsError is returned when the withdrawals of a block are needed to replay it,
+// but there is no node to fetch them from
+type MissingWithdrawalsError struct {
+	BlockNumber uint64
+}
+
+func (e *MissingWithdrawalsError) Error() string {
+	return fmt.Sprintf("withdrawals of block %d are not indexed, and no node is configured to fetch them from (ethereum.httpPath)",
+		e.BlockNumber)
+}
+
+// MissingBlockError is returned when no block is indexed at a height that should be validated
+type MissingBlockError struct {
+	BlockNumber uint64
+}
+
+func (e *MissingBlockError) Error() string {
+	return fmt.Sprintf("block %d is missing from the index", e.BlockNumber)
+}
+
+// LeafMismatch is a column of an indexed leaf which does not match the leaf in the trie
+type LeafMismatch struct {
+	LeafKey string
+	Field   string
+	Indexed interface{}
+	Trie    interface{}
+}
+
+// LeafMismatchError is returned when leaves indexed in a table don't match the leaves in the trie
+type LeafMismatchError struct {
+	BlockNumber uint64
+	Table       string
+	Mismatches  []LeafMismatch
+}
+
+func (e *LeafMismatchError) Error() string {
+	msgs := make([]string, len(e.Mismatches))
+	for i, m := range e.Mismatches {
+		msgs[i] = fmt.Sprintf("leaf %s %s (indexed: %v, trie: %v)", m.LeafKey, m.Field, m.Indexed, m.Trie)
+	}
+	return fmt.Sprintf("%d mismatched leaf fields in %s at block %d: %s",
+		len(e.Mismatches), e.Table, e.BlockNumber, strings.Join(msgs, "; "))
+}
+
+// ColumnMismatch is a column of an indexed row which does not match the decoded IPLD block
+type ColumnMismatch struct {
+	// Identifies the row, e.g. by block or transaction hash
+	Key     string
+	Column  string
+	Indexed interface{}
+	Decoded interface{}
+}
+
+// ColumnMismatchError is returned when rows indexed in a table don't match their IPLD blocks
+type ColumnMismatchError struct {
+	BlockNumber uint64
+	Table       string
+	Mismatches  []ColumnMismatch
+}
+
+func (e *ColumnMismatchError) Error() string {
+	msgs := make([]string, len(e.Mismatches))
+	for i, m := range e.Mismatches {
+		msgs[i] = fmt.Sprintf("%s %s (indexed: %v, ipld: %v)", m.Key, m.Column, m.Indexed, m.Decoded)
+	}
+	return fmt.Sprintf("%d mismatched columns in %s at block %d: %s",
+		len(e.Mismatches), e.Table, e.BlockNumber, strings.Join(msgs, "; "))
+}
@@ -0,0 +1,43 @@
+// VulcanizeDB
+// Copyright © 2023 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package validator
+
+import (
+	"fmt"
+)
+
+// FailurePolicy determines how the service proceeds when a block fails validation
+type FailurePolicy string
+
+const (
+	// Stop validating at the failed block
+	FailurePolicyHalt FailurePolicy = "halt"
+	// Record the failure and move on to the next block
+	FailurePolicySkip FailurePolicy = "skip"
+	// Retry validating the failed block, then record the failure and move on if it still fails
+	FailurePolicyRetryThenSkip FailurePolicy = "retry-then-skip"
+)
+
+// ParseFailurePolicy parses a failure policy from its string representation
+func ParseFailurePolicy(str string) (FailurePolicy, error) {
+	switch policy := FailurePolicy(str); policy {
+	case FailurePolicyHalt, FailurePolicySkip, FailurePolicyRetryThenSkip:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("invalid failure policy: %q", str)
+	}
+}
@@ -0,0 +1,27 @@
+package validator_test
+
+import (
+	"testing"
+
+	"github.com/cerc-io/ipld-eth-db-validator/v5/pkg/validator"
+)
+
+func TestParseFailurePolicy(t *testing.T) {
+	for _, policy := range []validator.FailurePolicy{
+		validator.FailurePolicyHalt,
+		validator.FailurePolicySkip,
+		validator.FailurePolicyRetryThenSkip,
+	} {
+		parsed, err := validator.ParseFailurePolicy(string(policy))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parsed != policy {
+			t.Fatalf("expected %s, got %s", policy, parsed)
+		}
+	}
+
+	if _, err := validator.ParseFailurePolicy("ignore"); err == nil {
+		t.Fatal("expected error for invalid policy")
+	}
+}
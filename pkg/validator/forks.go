@@ -0,0 +1,56 @@
+// VulcanizeDB
+// Copyright © 2023 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package validator
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/jmoiron/sqlx"
+
+	ipldeth "github.com/cerc-io/ipld-eth-server/v5/pkg/eth"
+)
+
+const headerHashesAtHeightPgStr = `SELECT block_hash FROM eth.header_cids WHERE block_number = $1`
+
+// nonCanonicalBlocks fetches every block indexed at the given height other than the canonical one
+func nonCanonicalBlocks(ctx context.Context, db *sqlx.DB, api *ipldeth.PublicEthAPI,
+	blockNum uint64, canonicalHash common.Hash) ([]*types.Block, error) {
+	var hashes []string
+	if err := db.Select(&hashes, headerHashesAtHeightPgStr, blockNum); err != nil {
+		return nil, err
+	}
+
+	var blocks []*types.Block
+	for _, hashStr := range hashes {
+		hash := common.HexToHash(hashStr)
+		if hash == canonicalHash {
+			continue
+		}
+		block, err := api.B.BlockByHash(ctx, hash)
+		if err != nil {
+			return nil, err
+		}
+		if block == nil {
+			return nil, fmt.Errorf("block %s at height %d not found", hash, blockNum)
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks, nil
+}
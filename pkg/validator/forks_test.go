@@ -0,0 +1,42 @@
+package validator_test
+
+import (
+	"testing"
+
+	server_mocks "github.com/cerc-io/ipld-eth-server/v5/pkg/eth/test_helpers"
+
+	"github.com/cerc-io/ipld-eth-db-validator/v5/pkg/validator"
+)
+
+// With allForks set, the non-canonical blocks at a height are validated along with the canonical one
+func TestAllForks(t *testing.T) {
+	db := setupStateValidator(t)
+	const validatorID = "test-all-forks"
+	clearValidator(t, db, validatorID)
+
+	blockNum := server_mocks.MockBlock.NumberU64()
+	cfg := serviceConfig(validatorID, blockNum)
+	cfg.ToBlock = blockNum
+	cfg.AllForks = true
+	cfg.FailurePolicy = validator.FailurePolicySkip
+	runService(t, cfg, 0)
+
+	var hashes []string
+	if err := db.Select(&hashes, `SELECT block_hash FROM validator.block_results WHERE validator_id = $1 AND block_number = $2`,
+		validatorID, blockNum); err != nil {
+		t.Fatal(err)
+	}
+	var canonicalHash string
+	if err := db.Get(&canonicalHash, `SELECT block_hash FROM eth.header_cids WHERE block_number = $1 AND block_hash <> $2`,
+		blockNum, server_mocks.MockBlock.Hash().String()); err != nil {
+		t.Fatal(err)
+	}
+	recorded := make(map[string]bool)
+	for _, hash := range hashes {
+		recorded[hash] = true
+	}
+	if len(hashes) != 2 || !recorded[canonicalHash] || !recorded[server_mocks.MockBlock.Hash().String()] {
+		t.Fatalf("expected results for the canonical block %s and the non-canonical block %s, got %v",
+			canonicalHash, server_mocks.MockBlock.Hash(), hashes)
+	}
+}
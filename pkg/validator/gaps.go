@@ -0,0 +1,188 @@
+// VulcanizeDB
+// Copyright © 2023 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package validator
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/jmoiron/sqlx"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	GapMissingHeader       = "missing header"
+	GapMissingState        = "missing state"
+	GapMissingTransactions = "missing transactions"
+)
+
+const (
+	maxHeaderHeightPgStr = `SELECT COALESCE(MAX(block_number), 0) FROM eth.header_cids`
+
+	// Selects heights in the range with no header, headers whose state root changed from their
+	// parent's but which have no state rows, and headers with a non-empty transactions root but
+	// no transaction rows
+	findGapsPgStr = `SELECT n AS block_number, ''::TEXT AS block_hash, $4::TEXT AS reason
+					FROM generate_series($1::BIGINT, $2::BIGINT) AS n
+					WHERE NOT EXISTS (
+						SELECT 1 FROM eth.header_cids WHERE header_cids.block_number = n
+					)
+					UNION ALL
+					SELECT header_cids.block_number, header_cids.block_hash, $5::TEXT
+					FROM eth.header_cids
+					LEFT JOIN eth.header_cids AS parent ON (
+						parent.block_hash = header_cids.parent_hash
+						AND parent.block_number = header_cids.block_number - 1
+					)
+					WHERE
+						header_cids.block_number BETWEEN $1 AND $2
+						AND header_cids.state_root IS DISTINCT FROM parent.state_root
+						AND NOT EXISTS (
+							SELECT 1 FROM eth.state_cids
+							WHERE state_cids.block_number = header_cids.block_number
+							AND state_cids.header_id = header_cids.block_hash
+						)
+					UNION ALL
+					SELECT header_cids.block_number, header_cids.block_hash, $6::TEXT
+					FROM eth.header_cids
+					WHERE
+						header_cids.block_number BETWEEN $1 AND $2
+						AND header_cids.tx_root <> $3
+						AND NOT EXISTS (
+							SELECT 1 FROM eth.transaction_cids
+							WHERE transaction_cids.block_number = header_cids.block_number
+							AND transaction_cids.header_id = header_cids.block_hash
+						)
+					ORDER BY block_number`
+)
+
+// Gap is a height at which data is missing from the index
+type Gap struct {
+	BlockNumber uint64 `db:"block_number"`
+	// Empty if the header itself is missing
+	BlockHash string `db:"block_hash"`
+	Reason    string `db:"reason"`
+}
+
+// GapFillConfig configures how gaps are filled by a statediffing node
+type GapFillConfig struct {
+	// Number of heights per batch of writeStateDiffAt calls
+	BatchSize uint
+	// Number of batches in flight at once
+	Concurrency uint
+	// Number of times to retry the heights in a batch that failed
+	Retries       uint
+	RetryInterval time.Duration
+	// Timeout for each batch
+	Timeout time.Duration
+}
+
+// FindGaps lists the heights in the range from-to with missing headers, state or transactions.
+// If to is 0, the range extends to the highest indexed header.
+func FindGaps(db *sqlx.DB, from, to uint64) ([]Gap, error) {
+	if to == 0 {
+		if err := db.Get(&to, maxHeaderHeightPgStr); err != nil {
+			return nil, err
+		}
+	}
+
+	var gaps []Gap
+	err := db.Select(&gaps, findGapsPgStr, from, to, types.EmptyRootHash.String(),
+		GapMissingHeader, GapMissingState, GapMissingTransactions)
+	return gaps, err
+}
+
+// GapHeights returns the distinct heights of the given gaps in ascending order
+func GapHeights(gaps []Gap) []uint64 {
+	seen := make(map[uint64]bool)
+	var heights []uint64
+	for _, gap := range gaps {
+		if !seen[gap.BlockNumber] {
+			seen[gap.BlockNumber] = true
+			heights = append(heights, gap.BlockNumber)
+		}
+	}
+	sort.Slice(heights, func(i, j int) bool { return heights[i] < heights[j] })
+	return heights
+}
+
+// FillGaps calls writeStateDiffAt on a statediffing node for each of the given heights, in
+// concurrent batches. It returns the error for each height that could not be filled.
+func FillGaps(ctx context.Context, client *rpc.Client, heights []uint64, cfg GapFillConfig) map[uint64]error {
+	batchSize := int(cfg.BatchSize)
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	concurrency := cfg.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var mtx sync.Mutex
+	failed := make(map[uint64]error)
+	sem := make(chan struct{}, concurrency)
+	wg := new(sync.WaitGroup)
+	for start := 0; start < len(heights); start += batchSize {
+		end := start + batchSize
+		if end > len(heights) {
+			end = len(heights)
+		}
+		batch := heights[start:end]
+
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			errs := fillBatch(ctx, client, batch, cfg)
+			mtx.Lock()
+			for height, err := range errs {
+				failed[height] = err
+			}
+			mtx.Unlock()
+		}()
+	}
+	wg.Wait()
+	return failed
+}
+
+// fillBatch fills a single batch of heights, retrying those that fail
+func fillBatch(ctx context.Context, client *rpc.Client, heights []uint64, cfg GapFillConfig) map[uint64]error {
+	log.Infof("calling writeStateDiffAt for blocks %d-%d", heights[0], heights[len(heights)-1])
+	failed := writeStateDiffBatch(ctx, client, cfg.Timeout, heights)
+	for attempt := uint(1); attempt <= cfg.Retries && len(failed) != 0; attempt++ {
+		select {
+		case <-ctx.Done():
+			return failed
+		case <-time.After(cfg.RetryInterval):
+		}
+
+		pending := make([]uint64, 0, len(failed))
+		for height, err := range failed {
+			log.Warnf("retrying writeStateDiffAt at block %d (attempt %d): %s", height, attempt, err)
+			pending = append(pending, height)
+		}
+		sort.Slice(pending, func(i, j int) bool { return pending[i] < pending[j] })
+		failed = writeStateDiffBatch(ctx, client, cfg.Timeout, pending)
+	}
+	return failed
+}
@@ -0,0 +1,180 @@
+package validator_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"github.com/cerc-io/ipld-eth-db-validator/v5/pkg/validator"
+)
+
+func TestFindGaps(t *testing.T) {
+	db := setupStateValidator(t)
+
+	blockHash := func(blockNum uint64) string {
+		var hash string
+		if err := db.Get(&hash, `SELECT block_hash FROM eth.header_cids WHERE block_number = $1`, blockNum); err != nil {
+			t.Fatal(err)
+		}
+		return hash
+	}
+
+	// The checked range starts above the non-canonical mock blocks, which have no state indexed
+	gaps, err := validator.FindGaps(db, 3, chainLength)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gaps) != 0 {
+		t.Fatalf("expected no gaps, got %+v", gaps)
+	}
+
+	// Block 3 has a transaction, and blocks 6 and above change the state by rewarding the miner
+	expected := []validator.Gap{
+		{BlockNumber: 3, BlockHash: blockHash(3), Reason: validator.GapMissingTransactions},
+		{BlockNumber: 4, Reason: validator.GapMissingHeader},
+		{BlockNumber: 6, BlockHash: blockHash(6), Reason: validator.GapMissingState},
+	}
+	for _, stm := range []string{
+		`DELETE FROM eth.transaction_cids WHERE block_number = 3`,
+		`DELETE FROM eth.header_cids WHERE block_number = 4`,
+		`DELETE FROM eth.state_cids WHERE block_number = 6`,
+	} {
+		if _, err := db.Exec(stm); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, to := range []uint64{chainLength, 0} {
+		gaps, err := validator.FindGaps(db, 3, to)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(gaps) != len(expected) {
+			t.Fatalf("to %d: expected gaps %+v, got %+v", to, expected, gaps)
+		}
+		for i := range expected {
+			if gaps[i] != expected[i] {
+				t.Fatalf("to %d: expected gaps %+v, got %+v", to, expected, gaps)
+			}
+		}
+	}
+}
+
+// stateDiffAPI serves writeStateDiffAt in the place of a statediffing node, failing the given heights
+type stateDiffAPI struct {
+	sync.Mutex
+	calls map[uint64]int
+	// Heights which fail on their first call, or on every call
+	failOnce, failAlways map[uint64]bool
+}
+
+func (api *stateDiffAPI) WriteStateDiffAt(blockNumber uint64, params json.RawMessage) error {
+	api.Lock()
+	defer api.Unlock()
+	api.calls[blockNumber]++
+	if api.failAlways[blockNumber] || (api.failOnce[blockNumber] && api.calls[blockNumber] == 1) {
+		return errors.New("failed to write state diff")
+	}
+	return nil
+}
+
+func TestFillGaps(t *testing.T) {
+	api := &stateDiffAPI{
+		calls:      make(map[uint64]int),
+		failOnce:   map[uint64]bool{2: true, 5: true},
+		failAlways: map[uint64]bool{6: true},
+	}
+	server := rpc.NewServer()
+	if err := server.RegisterName("statediff", api); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Stop()
+
+	// Track the size of each batch and the number of batches in flight
+	var mtx sync.Mutex
+	var batchSizes []int
+	var inFlight, maxInFlight int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var batch []json.RawMessage
+		if err := json.Unmarshal(body, &batch); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mtx.Lock()
+		batchSizes = append(batchSizes, len(batch))
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mtx.Unlock()
+		defer func() {
+			mtx.Lock()
+			inFlight--
+			mtx.Unlock()
+		}()
+
+		time.Sleep(50 * time.Millisecond)
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		server.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	client, err := rpc.Dial(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	const retries = 2
+	heights := []uint64{1, 2, 3, 4, 5, 6, 7}
+	failed := validator.FillGaps(context.Background(), client, heights, validator.GapFillConfig{
+		BatchSize:     3,
+		Concurrency:   2,
+		Retries:       retries,
+		RetryInterval: 10 * time.Millisecond,
+		Timeout:       10 * time.Second,
+	})
+
+	if len(failed) != 1 || failed[6] == nil {
+		t.Fatalf("expected only block 6 to fail, got %v", failed)
+	}
+	for _, height := range heights {
+		expected := 1
+		switch {
+		case api.failOnce[height]:
+			expected = 2
+		case api.failAlways[height]:
+			expected = 1 + retries
+		}
+		if api.calls[height] != expected {
+			t.Fatalf("expected %d calls for block %d, got %d", expected, height, api.calls[height])
+		}
+	}
+
+	// The first three requests are the initial batches, followed by retries of the failed heights
+	for _, size := range batchSizes {
+		if size > 3 {
+			t.Fatalf("expected batches of at most 3 calls, got %v", batchSizes)
+		}
+	}
+	if len(batchSizes) < 3 {
+		t.Fatalf("expected at least 3 batches, got %v", batchSizes)
+	}
+	if maxInFlight > 2 {
+		t.Fatalf("expected at most 2 batches in flight, got %d", maxInFlight)
+	}
+}
@@ -0,0 +1,102 @@
+// VulcanizeDB
+// Copyright © 2023 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package validator
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ipfs/go-cid"
+	"github.com/jmoiron/sqlx"
+	"github.com/multiformats/go-multihash"
+)
+
+var (
+	IPLDHashMismatchErr  = "ipld content check failed at block %d, data for %s referenced by %s does not match its hash"
+	IPLDCodecMismatchErr = "ipld content check failed at block %d, %s referenced by %s has codec %#x"
+)
+
+// Codec of eth log IPLDs, which is not among the go-cid constants
+const ethLogCodec = 0x9a
+
+// IPLDCodecs lists the CID codecs allowed for the IPLD blocks referenced by each CID table
+var IPLDCodecs = []struct {
+	CIDTable string
+	Codecs   []uint64
+}{
+	{"eth.header_cids", []uint64{cid.EthBlock}},
+	// Uncles may be referenced individually or as the block's uncle list
+	{"eth.uncle_cids", []uint64{cid.EthBlock, cid.EthBlockList}},
+	{"eth.transaction_cids", []uint64{cid.EthTx}},
+	{"eth.receipt_cids", []uint64{cid.EthTxReceipt}},
+	{"eth.state_cids", []uint64{cid.EthStateTrie}},
+	{"eth.storage_cids", []uint64{cid.EthStorageTrie}},
+	{"eth.log_cids", []uint64{ethLogCodec}},
+}
+
+// ValidateIPLDBlocksContent checks that the data of each IPLD block referenced at the given height
+// hashes to its CID, and that the CID's codec matches the table referencing it
+func ValidateIPLDBlocksContent(tx *sqlx.Tx, blockNumber uint64) error {
+	for _, table := range IPLDCodecs {
+		if err := validateIPLDContent(tx, blockNumber, table.CIDTable, table.Codecs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateIPLDContent checks the IPLD blocks referenced by the given CID table at a height
+func validateIPLDContent(tx *sqlx.Tx, blockNumber uint64, cidTable string, codecs []uint64) error {
+	rows, err := tx.Queryx(fmt.Sprintf(CIDsIPLDBlocksData, cidTable, "cid"), blockNumber)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key string
+		var data []byte
+		if err := rows.Scan(&key, &data); err != nil {
+			return err
+		}
+		c, err := cid.Decode(key)
+		if err != nil {
+			return fmt.Errorf("invalid CID %s referenced by %s at block %d: %w", key, cidTable, blockNumber, err)
+		}
+		if !containsCodec(codecs, c.Type()) {
+			return fmt.Errorf(IPLDCodecMismatchErr, blockNumber, key, cidTable, c.Type())
+		}
+		mh, err := multihash.Decode(c.Hash())
+		if err != nil {
+			return fmt.Errorf("invalid multihash in CID %s referenced by %s at block %d: %w", key, cidTable, blockNumber, err)
+		}
+		if mh.Code != multihash.KECCAK_256 || !bytes.Equal(mh.Digest, crypto.Keccak256(data)) {
+			return fmt.Errorf(IPLDHashMismatchErr, blockNumber, key, cidTable)
+		}
+	}
+	return rows.Err()
+}
+
+func containsCodec(codecs []uint64, codec uint64) bool {
+	for _, c := range codecs {
+		if c == codec {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+// VulcanizeDB
+// Copyright © 2023 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package validator
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+
+	ipldstate "github.com/cerc-io/ipld-eth-statedb/trie_by_cid/state"
+)
+
+// IrregularStateChange applies a one-off state transition defined by the chain config, such as the
+// DAO hard fork balance transfer. It is called for every replayed block before its transactions are
+// applied, and should be a no-op for blocks it does not apply to.
+type IrregularStateChange func(config *params.ChainConfig, header *types.Header, state *ipldstate.StateDB)
+
+var irregularStateChanges = []IrregularStateChange{
+	applyDAOHardFork,
+}
+
+// RegisterIrregularStateChange adds a state change to be applied during block replay.
+// It must be called before validation is started.
+func RegisterIrregularStateChange(change IrregularStateChange) {
+	irregularStateChanges = append(irregularStateChanges, change)
+}
+
+func applyIrregularStateChanges(config *params.ChainConfig, header *types.Header, state *ipldstate.StateDB) {
+	for _, change := range irregularStateChanges {
+		change(config, header, state)
+	}
+}
+
+// applyDAOHardFork performs the DAO hard fork balance transfer at the fork block, if the chain
+// supports it. This mirrors misc.ApplyDAOHardFork, which only accepts a geth state.StateDB.
+func applyDAOHardFork(config *params.ChainConfig, header *types.Header, state *ipldstate.StateDB) {
+	if !config.DAOForkSupport || config.DAOForkBlock == nil || config.DAOForkBlock.Cmp(header.Number) != 0 {
+		return
+	}
+
+	// Retrieve the contract to refund balances into
+	if !state.Exist(params.DAORefundContract) {
+		state.CreateAccount(params.DAORefundContract)
+	}
+
+	// Move every DAO account and extra-balance account funds into the refund contract
+	for _, addr := range params.DAODrainList() {
+		state.AddBalance(params.DAORefundContract, state.GetBalance(addr))
+		state.SetBalance(addr, new(big.Int))
+	}
+}
@@ -0,0 +1,83 @@
+package validator_test
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/cerc-io/plugeth-statediff/test_helpers"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"github.com/cerc-io/ipld-eth-db-validator/v5/internal/chaingen"
+	"github.com/cerc-io/ipld-eth-db-validator/v5/internal/helpers"
+	"github.com/cerc-io/ipld-eth-db-validator/v5/pkg/validator"
+)
+
+// Replaying the DAO fork block requires the balance transfer to be applied
+func TestIrregularStateChanges(t *testing.T) {
+	daoConfig := *chainConfig
+	daoConfig.DAOForkBlock = big.NewInt(2)
+	daoConfig.DAOForkSupport = true
+
+	// Fund a drained account, so that the transfer changes the state root
+	drained := params.DAODrainList()[0]
+	gen := chaingen.DefaultGenContext(&daoConfig, rawdb.NewMemoryDatabase())
+	gen.AddFunction(func(i int, block *core.BlockGen) {
+		if i != 0 {
+			return
+		}
+		tx, err := gen.CreateSendTx(crypto.PubkeyToAddress(test_helpers.TestBankKey.PublicKey), drained, big.NewInt(1000))
+		if err != nil {
+			panic(err)
+		}
+		block.AddTx(tx)
+	})
+	blocks, receipts, chain := gen.MakeChain(3)
+	t.Cleanup(func() {
+		chain.Stop()
+	})
+
+	forkState, err := chain.StateAt(blocks[2].Root())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if forkState.GetBalance(params.DAORefundContract).Sign() == 0 {
+		t.Fatal("expected the DAO refund contract to be funded at the fork block")
+	}
+
+	indexer, err := helpers.TestStateDiffIndexer(context.Background(), &daoConfig, gen.Genesis.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := helpers.IndexChain(indexer, helpers.IndexChainParams{
+		StateCache: chain.StateCache(),
+		Blocks:     blocks,
+		Receipts:   receipts,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	db := helpers.SetupDB()
+	t.Cleanup(func() {
+		helpers.TearDownDB(db)
+	})
+
+	api, err := validator.EthAPI(context.Background(), helpers.SetupDB(), &daoConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer api.B.Close()
+
+	for i := uint64(startBlock); i < uint64(len(blocks)); i++ {
+		block, err := api.B.BlockByNumber(context.Background(), rpc.BlockNumber(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := validator.ValidateBlock(block, api.B, i); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
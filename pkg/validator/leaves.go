@@ -0,0 +1,194 @@
+// VulcanizeDB
+// Copyright © 2023 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package validator
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/jmoiron/sqlx"
+
+	ipldstate "github.com/cerc-io/ipld-eth-statedb/trie_by_cid/state"
+	ipldtrie "github.com/cerc-io/ipld-eth-statedb/trie_by_cid/trie"
+)
+
+type stateLeafRow struct {
+	StateRoot   string         `db:"state_root"`
+	LeafKey     string         `db:"state_leaf_key"`
+	Balance     sql.NullString `db:"balance"`
+	Nonce       sql.NullInt64  `db:"nonce"`
+	CodeHash    sql.NullString `db:"code_hash"`
+	StorageRoot sql.NullString `db:"storage_root"`
+	Removed     bool           `db:"removed"`
+}
+
+// ValidateStateLeaves checks that the account columns of each eth.state_cids row at the given
+// height match the account leaf in the state trie at its header's state root
+func ValidateStateLeaves(tx *sqlx.Tx, stateDB ipldstate.Database, blockNumber uint64) error {
+	var rows []stateLeafRow
+	if err := tx.Select(&rows, StateCIDsLeaves, blockNumber); err != nil {
+		return err
+	}
+
+	tries := newStateTries(stateDB)
+	var mismatches []LeafMismatch
+	for _, row := range rows {
+		account, err := tries.account(common.HexToHash(row.StateRoot), common.HexToHash(row.LeafKey))
+		if err != nil {
+			return fmt.Errorf("failed to resolve state leaf %s at block %d: %w", row.LeafKey, blockNumber, err)
+		}
+		if row.Removed || account == nil {
+			if row.Removed != (account == nil) {
+				mismatches = append(mismatches, LeafMismatch{row.LeafKey, "removed", row.Removed, account == nil})
+			}
+			continue
+		}
+
+		if account.Balance.String() != row.Balance.String {
+			mismatches = append(mismatches, LeafMismatch{row.LeafKey, "balance", row.Balance.String, account.Balance})
+		}
+		if account.Nonce != uint64(row.Nonce.Int64) {
+			mismatches = append(mismatches, LeafMismatch{row.LeafKey, "nonce", row.Nonce.Int64, account.Nonce})
+		}
+		if codeHash := common.BytesToHash(account.CodeHash); codeHash != common.HexToHash(row.CodeHash.String) {
+			mismatches = append(mismatches, LeafMismatch{row.LeafKey, "code_hash", row.CodeHash.String, codeHash})
+		}
+		if account.Root != common.HexToHash(row.StorageRoot.String) {
+			mismatches = append(mismatches, LeafMismatch{row.LeafKey, "storage_root", row.StorageRoot.String, account.Root})
+		}
+	}
+	if len(mismatches) != 0 {
+		return &LeafMismatchError{blockNumber, "eth.state_cids", mismatches}
+	}
+	return nil
+}
+
+type storageLeafRow struct {
+	StateRoot      string `db:"state_root"`
+	StateLeafKey   string `db:"state_leaf_key"`
+	StorageLeafKey string `db:"storage_leaf_key"`
+	Value          []byte `db:"val"`
+	Removed        bool   `db:"removed"`
+}
+
+// ValidateStorageLeaves checks that the value and removed flag of each eth.storage_cids row at the
+// given height match the leaf in the account's storage trie, at its header's state root
+func ValidateStorageLeaves(tx *sqlx.Tx, stateDB ipldstate.Database, blockNumber uint64) error {
+	var rows []storageLeafRow
+	if err := tx.Select(&rows, StorageCIDsLeaves, blockNumber); err != nil {
+		return err
+	}
+
+	tries := newStateTries(stateDB)
+	// Storage tries by state root and account leaf key
+	storageTries := make(map[[2]common.Hash]ipldstate.Trie)
+	var mismatches []LeafMismatch
+	for _, row := range rows {
+		stateRoot, stateLeafKey := common.HexToHash(row.StateRoot), common.HexToHash(row.StateLeafKey)
+		leafKey := row.StateLeafKey + "/" + row.StorageLeafKey
+
+		storageTrie, ok := storageTries[[2]common.Hash{stateRoot, stateLeafKey}]
+		if !ok {
+			account, err := tries.account(stateRoot, stateLeafKey)
+			if err != nil {
+				return fmt.Errorf("failed to resolve state leaf %s at block %d: %w", row.StateLeafKey, blockNumber, err)
+			}
+			storageRoot := types.EmptyRootHash
+			if account != nil {
+				storageRoot = account.Root
+			}
+			storageTrie, err = stateDB.OpenStorageTrie(stateRoot, stateLeafKey, storageRoot)
+			if err != nil {
+				return fmt.Errorf("failed to open storage trie of %s at block %d: %w", row.StateLeafKey, blockNumber, err)
+			}
+			storageTries[[2]common.Hash{stateRoot, stateLeafKey}] = storageTrie
+		}
+
+		value, err := trieLeaf(storageTrie, common.HexToHash(row.StorageLeafKey))
+		if err != nil {
+			return fmt.Errorf("failed to resolve storage leaf %s at block %d: %w", leafKey, blockNumber, err)
+		}
+		if row.Removed || value == nil {
+			if row.Removed != (value == nil) {
+				mismatches = append(mismatches, LeafMismatch{leafKey, "removed", row.Removed, value == nil})
+			}
+			continue
+		}
+
+		// Both the indexed value and the trie leaf are RLP encoded
+		trieValue, _, err := rlp.SplitString(value)
+		if err != nil {
+			return fmt.Errorf("failed to decode storage leaf %s at block %d: %w", leafKey, blockNumber, err)
+		}
+		indexedValue, _, err := rlp.SplitString(row.Value)
+		if err != nil || !bytes.Equal(indexedValue, trieValue) {
+			mismatches = append(mismatches, LeafMismatch{leafKey, "val",
+				hexutil.Bytes(row.Value), hexutil.Bytes(value)})
+		}
+	}
+	if len(mismatches) != 0 {
+		return &LeafMismatchError{blockNumber, "eth.storage_cids", mismatches}
+	}
+	return nil
+}
+
+// stateTries opens the state trie at each state root once
+type stateTries struct {
+	db    ipldstate.Database
+	tries map[common.Hash]ipldstate.Trie
+}
+
+func newStateTries(db ipldstate.Database) *stateTries {
+	return &stateTries{db: db, tries: make(map[common.Hash]ipldstate.Trie)}
+}
+
+// account returns the account at the given hashed key in the state trie with the given root, or
+// nil if there is no such account
+func (s *stateTries) account(root, leafKey common.Hash) (*types.StateAccount, error) {
+	t, ok := s.tries[root]
+	if !ok {
+		var err error
+		if t, err = s.db.OpenTrie(root); err != nil {
+			return nil, err
+		}
+		s.tries[root] = t
+	}
+	value, err := trieLeaf(t, leafKey)
+	if value == nil || err != nil {
+		return nil, err
+	}
+	var account types.StateAccount
+	if err := rlp.DecodeBytes(value, &account); err != nil {
+		return nil, fmt.Errorf("invalid account: %w", err)
+	}
+	return &account, nil
+}
+
+// trieLeaf returns the value of the leaf at the given hashed key in the trie, or nil if there is
+// no such leaf. The trie is keyed by hash, so the leaf is found by seeking an iterator to the key.
+func trieLeaf(t ipldstate.Trie, key common.Hash) ([]byte, error) {
+	it := ipldtrie.NewIterator(t.NodeIterator(key.Bytes()))
+	if it.Next() && bytes.Equal(it.Key, key.Bytes()) {
+		return it.Value, nil
+	}
+	return nil, it.Err
+}
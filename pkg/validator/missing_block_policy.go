@@ -0,0 +1,43 @@
+// VulcanizeDB
+// Copyright © 2023 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package validator
+
+import (
+	"fmt"
+)
+
+// MissingBlockPolicy determines how the service proceeds when no block is indexed at a height
+type MissingBlockPolicy string
+
+const (
+	// Treat the missing block as a validation failure, handled according to the failure policy
+	MissingBlockPolicyFail MissingBlockPolicy = "fail"
+	// Record the missing block as a failure and move on to the next block
+	MissingBlockPolicyRecordAndSkip MissingBlockPolicy = "record-and-skip"
+	// Fill the gap using a statediffing node, wait for the block to be indexed and validate it
+	MissingBlockPolicyFillAndRevalidate MissingBlockPolicy = "fill-and-revalidate"
+)
+
+// ParseMissingBlockPolicy parses a missing block policy from its string representation
+func ParseMissingBlockPolicy(str string) (MissingBlockPolicy, error) {
+	switch policy := MissingBlockPolicy(str); policy {
+	case MissingBlockPolicyFail, MissingBlockPolicyRecordAndSkip, MissingBlockPolicyFillAndRevalidate:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("invalid missing block policy: %q", str)
+	}
+}
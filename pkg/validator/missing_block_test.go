@@ -0,0 +1,146 @@
+package validator_test
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/cerc-io/ipld-eth-db-validator/v5/pkg/validator"
+)
+
+// The block at this height is hidden from the canonical chain, so it can't be fetched by number but
+// its child can still be replayed on top of it
+const missingBlock = 4
+
+const setCanonicalAtPgStr = `UPDATE eth.header_cids SET canonical = $2 WHERE block_number = $1`
+
+// fillAPI stands in for a statediffing node, indexing a hidden block by making it canonical again
+type fillAPI struct {
+	db *sqlx.DB
+	// If set, the call succeeds without indexing anything
+	noop bool
+}
+
+func (api *fillAPI) WriteStateDiffAt(blockNumber uint64, params json.RawMessage) error {
+	if api.noop {
+		return nil
+	}
+	_, err := api.db.Exec(setCanonicalAtPgStr, blockNumber, true)
+	return err
+}
+
+func serveFillAPI(t *testing.T, api *fillAPI) *rpc.Client {
+	server := rpc.NewServer()
+	if err := server.RegisterName("statediff", api); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(server)
+	client, err := rpc.Dial(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		client.Close()
+		srv.Close()
+		server.Stop()
+	})
+	return client
+}
+
+type blockResult struct {
+	BlockHash string `db:"block_hash"`
+	Passed    bool   `db:"passed"`
+	Error     string `db:"error"`
+}
+
+func blockResults(t *testing.T, db *sqlx.DB, validatorID string, blockNum uint64) []blockResult {
+	var results []blockResult
+	if err := db.Select(&results, `SELECT block_hash, passed, COALESCE(error, '') AS error FROM validator.block_results
+									WHERE validator_id = $1 AND block_number = $2 ORDER BY id`,
+		validatorID, blockNum); err != nil {
+		t.Fatal(err)
+	}
+	return results
+}
+
+// assertMissingResult checks that a single failed result was recorded for the missing block
+func assertMissingResult(t *testing.T, db *sqlx.DB, validatorID string) {
+	results := blockResults(t, db, validatorID, missingBlock)
+	expectedErr := (&validator.MissingBlockError{BlockNumber: missingBlock}).Error()
+	if len(results) != 1 || results[0].Passed || results[0].Error != expectedErr ||
+		results[0].BlockHash != (common.Hash{}).String() {
+		t.Fatalf("expected a missing block result for block %d, got %+v", missingBlock, results)
+	}
+}
+
+func TestMissingBlockPolicy(t *testing.T) {
+	db := setupStateValidator(t)
+
+	// Each case hides the block again, and validates the range around it
+	setup := func(t *testing.T, validatorID string, policy validator.MissingBlockPolicy) *validator.Config {
+		clearValidator(t, db, validatorID)
+		if _, err := db.Exec(setCanonicalAtPgStr, missingBlock, false); err != nil {
+			t.Fatal(err)
+		}
+		cfg := serviceConfig(validatorID, missingBlock-1)
+		cfg.ToBlock = missingBlock + 1
+		cfg.MissingBlockPolicy = policy
+		return cfg
+	}
+
+	t.Run("Fail", func(t *testing.T) {
+		const validatorID = "test-missing-fail"
+		cfg := setup(t, validatorID, validator.MissingBlockPolicyFail)
+		service, validated := runService(t, cfg, 0)
+
+		// The missing block is handled by the halt failure policy
+		assertHeights(t, []uint64{missingBlock - 1}, validated)
+		assertMissingResult(t, db, validatorID)
+		if summary := service.Summary(); summary.Passed() {
+			t.Fatalf("expected the summary to fail, got %s", &summary)
+		}
+		if results := blockResults(t, db, validatorID, missingBlock+1); len(results) != 0 {
+			t.Fatalf("expected no results past the missing block, got %+v", results)
+		}
+	})
+
+	t.Run("Record and skip", func(t *testing.T) {
+		const validatorID = "test-missing-skip"
+		cfg := setup(t, validatorID, validator.MissingBlockPolicyRecordAndSkip)
+		_, validated := runService(t, cfg, 0)
+
+		assertHeights(t, []uint64{missingBlock - 1, missingBlock + 1}, validated)
+		assertMissingResult(t, db, validatorID)
+	})
+
+	t.Run("Fill and revalidate", func(t *testing.T) {
+		const validatorID = "test-missing-fill"
+		cfg := setup(t, validatorID, validator.MissingBlockPolicyFillAndRevalidate)
+		cfg.Client = serveFillAPI(t, &fillAPI{db: db})
+		cfg.StateDiffTimeout = 10 * time.Second
+		_, validated := runService(t, cfg, 0)
+
+		assertHeights(t, []uint64{missingBlock - 1, missingBlock, missingBlock + 1}, validated)
+		results := blockResults(t, db, validatorID, missingBlock)
+		if len(results) != 1 || !results[0].Passed {
+			t.Fatalf("expected block %d to pass once filled, got %+v", missingBlock, results)
+		}
+	})
+
+	t.Run("Fill timeout", func(t *testing.T) {
+		const validatorID = "test-missing-fill-timeout"
+		cfg := setup(t, validatorID, validator.MissingBlockPolicyFillAndRevalidate)
+		cfg.Client = serveFillAPI(t, &fillAPI{noop: true})
+		cfg.StateDiffTimeout = 200 * time.Millisecond
+		_, validated := runService(t, cfg, 0)
+
+		// The block is never indexed, so waiting for it times out and the failure policy halts
+		assertHeights(t, []uint64{missingBlock - 1}, validated)
+		assertMissingResult(t, db, validatorID)
+	})
+}
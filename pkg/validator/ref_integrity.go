@@ -19,62 +19,72 @@ package validator
 import (
 	"fmt"
 
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ipfs/go-cid"
 	"github.com/jmoiron/sqlx"
+
+	ipfsethdb "github.com/cerc-io/ipfs-ethdb/v5/postgres/v0"
+	ipldstate "github.com/cerc-io/ipld-eth-statedb/trie_by_cid/state"
 )
 
 var (
 	ReferentialIntegrityErr = "referential integrity check failed at block %d, entry for %s not found"
 	EntryNotFoundErr        = "entry for %s not found"
-)
-
-// ValidateReferentialIntegrity validates referential integrity at the given height
-func ValidateReferentialIntegrity(tx *sqlx.Tx, blockNumber uint64) error {
-	err := ValidateHeaderCIDsRef(tx, blockNumber)
-	if err != nil {
-		return err
-	}
 
-	err = ValidateUncleCIDsRef(tx, blockNumber)
-	if err != nil {
-		return err
-	}
-
-	err = ValidateTransactionCIDsRef(tx, blockNumber)
-	if err != nil {
-		return err
-	}
-
-	err = ValidateReceiptCIDsRef(tx, blockNumber)
-	if err != nil {
-		return err
-	}
+	emptyCodeHash = crypto.Keccak256Hash(nil)
+)
 
-	err = ValidateStateCIDsRef(tx, blockNumber)
-	if err != nil {
-		return err
-	}
+// ReferentialIntegrityCheck is a named referential integrity check on the data at a given height
+type ReferentialIntegrityCheck struct {
+	Name     string
+	Validate func(tx *sqlx.Tx, blockNumber uint64) error
+}
 
-	err = ValidateStorageCIDsRef(tx, blockNumber)
-	if err != nil {
-		return err
+// ReferentialIntegrityChecks lists the checks performed by ValidateReferentialIntegrity for the
+// given chain, in order. Leaves are looked up in the tries opened with the given state database.
+func ReferentialIntegrityChecks(config *params.ChainConfig, stateDB ipldstate.Database) []ReferentialIntegrityCheck {
+	return []ReferentialIntegrityCheck{
+		{"header_cids", ValidateHeaderCIDsRef},
+		{"uncle_cids", ValidateUncleCIDsRef},
+		{"transaction_cids", func(tx *sqlx.Tx, blockNumber uint64) error {
+			return ValidateTransactionCIDsRef(tx, config, blockNumber)
+		}},
+		{"receipt_cids", ValidateReceiptCIDsRef},
+		{"state_cids", ValidateStateCIDsRef},
+		{"state_code", ValidateStateCodeRef},
+		{"storage_cids", ValidateStorageCIDsRef},
+		{"log_cids", ValidateLogCIDsRef},
+		{"ipld_blocks", ValidateIPLDBlocksContent},
+		{"state_leaves", func(tx *sqlx.Tx, blockNumber uint64) error {
+			return ValidateStateLeaves(tx, stateDB, blockNumber)
+		}},
+		{"storage_leaves", func(tx *sqlx.Tx, blockNumber uint64) error {
+			return ValidateStorageLeaves(tx, stateDB, blockNumber)
+		}},
 	}
+}
 
-	err = ValidateLogCIDsRef(tx, blockNumber)
-	if err != nil {
-		return err
+// ValidateReferentialIntegrity validates referential integrity at the given height
+func ValidateReferentialIntegrity(tx *sqlx.Tx, config *params.ChainConfig, stateDB ipldstate.Database, blockNumber uint64) error {
+	for _, check := range ReferentialIntegrityChecks(config, stateDB) {
+		if err := check.Validate(tx, blockNumber); err != nil {
+			return err
+		}
 	}
-
 	return nil
 }
 
-// ValidateHeaderCIDsRef does a reference integrity check on references in eth.header_cids table
+// ValidateHeaderCIDsRef does a reference integrity check on references in eth.header_cids table,
+// and checks its columns against the header IPLD
 func ValidateHeaderCIDsRef(tx *sqlx.Tx, blockNumber uint64) error {
 	err := ValidateIPFSBlocks(tx, blockNumber, "eth.header_cids", "cid")
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return ValidateHeaderColumns(tx, blockNumber)
 }
 
 // ValidateUncleCIDsRef does a reference integrity check on references in eth.uncle_cids table
@@ -96,8 +106,9 @@ func ValidateUncleCIDsRef(tx *sqlx.Tx, blockNumber uint64) error {
 	return nil
 }
 
-// ValidateTransactionCIDsRef does a reference integrity check on references in eth.header_cids table
-func ValidateTransactionCIDsRef(tx *sqlx.Tx, blockNumber uint64) error {
+// ValidateTransactionCIDsRef does a reference integrity check on references in eth.transaction_cids table,
+// and checks its columns against the transaction IPLDs
+func ValidateTransactionCIDsRef(tx *sqlx.Tx, config *params.ChainConfig, blockNumber uint64) error {
 	var exists bool
 	err := tx.Get(&exists, TransactionCIDsRefHeaderCIDs, blockNumber)
 	if err != nil {
@@ -112,7 +123,7 @@ func ValidateTransactionCIDsRef(tx *sqlx.Tx, blockNumber uint64) error {
 		return err
 	}
 
-	return nil
+	return ValidateTransactionColumns(tx, config, blockNumber)
 }
 
 // ValidateReceiptCIDsRef does a reference integrity check on references in eth.receipt_cids table
@@ -153,6 +164,37 @@ func ValidateStateCIDsRef(tx *sqlx.Tx, blockNumber uint64) error {
 	return nil
 }
 
+// ValidateStateCodeRef checks that the contract code for each code hash in eth.state_cids table
+// is present in ipld.blocks, where it may have been indexed at an earlier height
+func ValidateStateCodeRef(tx *sqlx.Tx, blockNumber uint64) error {
+	var codeHashes []string
+	err := tx.Select(&codeHashes, StateCIDsCodeHashes, blockNumber)
+	if err != nil {
+		return err
+	}
+
+	for _, hashStr := range codeHashes {
+		codeHash := common.HexToHash(hashStr)
+		if codeHash == emptyCodeHash {
+			continue
+		}
+		c, err := ipfsethdb.CIDFromKeccak256(codeHash.Bytes(), cid.Raw)
+		if err != nil {
+			return err
+		}
+		var exists bool
+		err = tx.Get(&exists, IPLDBlockExists, c.String())
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf(ReferentialIntegrityErr+" (code hash %s)", blockNumber, "ipld.blocks", codeHash)
+		}
+	}
+
+	return nil
+}
+
 // ValidateStorageCIDsRef does a reference integrity check on references in eth.storage_cids table
 func ValidateStorageCIDsRef(tx *sqlx.Tx, blockNumber uint64) error {
 	var exists bool
@@ -35,6 +35,42 @@ const (
 							AND blocks.key IS NULL
 					)`
 
+	CIDsIPLDBlocksData = `SELECT blocks.key, blocks.data
+						FROM %[1]s
+						INNER JOIN ipld.blocks ON (
+							%[1]s.%[2]s = blocks.key
+							AND %[1]s.block_number = blocks.block_number
+						)
+						WHERE %[1]s.block_number = $1`
+
+	HeaderCIDsColumns = `SELECT header_cids.block_hash, header_cids.parent_hash, header_cids.td,
+							header_cids.state_root, header_cids.tx_root, header_cids.receipt_root,
+							header_cids.uncles_hash, header_cids.bloom, header_cids.timestamp,
+							header_cids.coinbase, blocks.data, parent.td AS parent_td
+						FROM eth.header_cids
+						INNER JOIN ipld.blocks ON (
+							header_cids.cid = blocks.key
+							AND header_cids.block_number = blocks.block_number
+						)
+						LEFT JOIN eth.header_cids AS parent ON (
+							parent.block_hash = header_cids.parent_hash
+							AND parent.block_number = header_cids.block_number - 1
+						)
+						WHERE header_cids.block_number = $1`
+
+	HeaderCIDsTxRoots = `SELECT block_hash, tx_root FROM eth.header_cids WHERE block_number = $1`
+
+	TransactionCIDsColumns = `SELECT transaction_cids.header_id, transaction_cids.tx_hash, transaction_cids.index,
+							transaction_cids.src, transaction_cids.dst, transaction_cids.value,
+							transaction_cids.tx_type, blocks.data
+						FROM eth.transaction_cids
+						INNER JOIN ipld.blocks ON (
+							transaction_cids.cid = blocks.key
+							AND transaction_cids.block_number = blocks.block_number
+						)
+						WHERE transaction_cids.block_number = $1
+						ORDER BY transaction_cids.header_id, transaction_cids.index`
+
 	UncleCIDsRefHeaderCIDs = `SELECT EXISTS (
 						SELECT *
 						FROM eth.uncle_cids
@@ -84,6 +120,15 @@ const (
 							AND header_cids.block_hash IS NULL
 					)`
 
+	StateCIDsCodeHashes = `SELECT DISTINCT code_hash
+						FROM eth.state_cids
+						WHERE
+							block_number = $1
+							AND NOT removed
+							AND code_hash IS NOT NULL`
+
+	IPLDBlockExists = `SELECT EXISTS (SELECT 1 FROM ipld.blocks WHERE key = $1)`
+
 	StorageCIDsRefStateCIDs = `SELECT EXISTS (
 						SELECT *
 						FROM eth.storage_cids
@@ -110,3 +155,27 @@ const (
 							AND receipt_cids.tx_id IS NULL
 					)`
 )
+
+// Queries to cross-check the leaf columns in the indexed data against the tries:
+// At the given block number, select each leaf row along with the state root of its header,
+// so that the leaf can be resolved through the trie at that root.
+
+const (
+	StateCIDsLeaves = `SELECT header_cids.state_root, state_cids.state_leaf_key, state_cids.balance,
+							state_cids.nonce, state_cids.code_hash, state_cids.storage_root, state_cids.removed
+						FROM eth.state_cids
+						INNER JOIN eth.header_cids ON (
+							state_cids.header_id = header_cids.block_hash
+							AND state_cids.block_number = header_cids.block_number
+						)
+						WHERE state_cids.block_number = $1`
+
+	StorageCIDsLeaves = `SELECT header_cids.state_root, storage_cids.state_leaf_key, storage_cids.storage_leaf_key,
+							storage_cids.val, storage_cids.removed
+						FROM eth.storage_cids
+						INNER JOIN eth.header_cids ON (
+							storage_cids.header_id = header_cids.block_hash
+							AND storage_cids.block_number = header_cids.block_number
+						)
+						WHERE storage_cids.block_number = $1`
+)
@@ -2,6 +2,7 @@ package validator_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math/big"
 	"testing"
@@ -10,10 +11,15 @@ import (
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ipfs/go-cid"
 	"github.com/jmoiron/sqlx"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 
+	ipfsethdb "github.com/cerc-io/ipfs-ethdb/v5/postgres/v0"
+	ipldeth "github.com/cerc-io/ipld-eth-server/v5/pkg/eth"
+
 	"github.com/cerc-io/ipld-eth-db-validator/v5/internal/chaingen"
 	"github.com/cerc-io/ipld-eth-db-validator/v5/internal/helpers"
 	"github.com/cerc-io/ipld-eth-db-validator/v5/pkg/validator"
@@ -28,15 +34,16 @@ var _ = Describe("referential integrity", Ordered, func() {
 	var (
 		db           *sqlx.DB
 		tx           *sqlx.Tx
+		api          *ipldeth.PublicEthAPI
+		chain        *core.BlockChain
 		checkedBlock *types.Block // Generated block of interest
+		storageBlock *types.Block // Generated block which updates contract storage
 	)
 	BeforeAll(func() {
 		var (
 			blocks      []*types.Block
 			receipts    []types.Receipts
-			chain       *core.BlockChain
 			chainConfig = TestChainConfig
-			mockTD      = big.NewInt(1337)
 			testdb      = rawdb.NewMemoryDatabase()
 		)
 
@@ -58,16 +65,21 @@ var _ = Describe("referential integrity", Ordered, func() {
 		indexer, err := helpers.TestStateDiffIndexer(context.Background(), chainConfig, gen.Genesis.Hash())
 		Expect(err).ToNot(HaveOccurred())
 		helpers.IndexChain(indexer, helpers.IndexChainParams{
-			StateCache:      chain.StateCache(),
-			Blocks:          blocks,
-			Receipts:        receipts,
-			TotalDifficulty: mockTD,
+			StateCache: chain.StateCache(),
+			Blocks:     blocks,
+			Receipts:   receipts,
 		})
 		checkedBlock = blocks[5]
+		storageBlock = blocks[4]
 
 		db = helpers.SetupDB()
+		api, err = validator.EthAPI(context.Background(), helpers.SetupDB(), chainConfig)
+		Expect(err).ToNot(HaveOccurred())
+	})
+	AfterAll(func() {
+		api.B.Close()
+		helpers.TearDownDB(db)
 	})
-	AfterAll(func() { helpers.TearDownDB(db) })
 
 	BeforeEach(func() { tx = db.MustBegin() })
 	AfterEach(func() {
@@ -88,6 +100,18 @@ var _ = Describe("referential integrity", Ordered, func() {
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(ContainSubstring(validator.EntryNotFoundErr, "ipld.blocks"))
 		})
+
+		It("Throws an error if a header column does not match the header IPLD block", func() {
+			_, err := tx.Exec(`UPDATE eth.header_cids SET state_root = $1 WHERE block_number = $2`,
+				common.HexToHash("0x1").String(), checkedBlock.NumberU64())
+			Expect(err).ToNot(HaveOccurred())
+
+			err = validator.ValidateHeaderCIDsRef(tx, checkedBlock.NumberU64())
+			Expect(err).To(HaveOccurred())
+			var mismatchErr *validator.ColumnMismatchError
+			Expect(errors.As(err, &mismatchErr)).To(BeTrue())
+			Expect(mismatchErr.Mismatches[0].Column).To(Equal("state_root"))
+		})
 	})
 
 	Describe("ValidateUncleCIDsRef", func() {
@@ -117,7 +141,7 @@ var _ = Describe("referential integrity", Ordered, func() {
 
 	Describe("ValidateTransactionCIDsRef", func() {
 		It("Validates referential integrity of transaction_cids table", func() {
-			err := validator.ValidateTransactionCIDsRef(tx, checkedBlock.NumberU64())
+			err := validator.ValidateTransactionCIDsRef(tx, TestChainConfig, checkedBlock.NumberU64())
 			Expect(err).ToNot(HaveOccurred())
 		})
 
@@ -125,7 +149,7 @@ var _ = Describe("referential integrity", Ordered, func() {
 			err := deleteEntriesFrom(tx, "eth.header_cids")
 			Expect(err).ToNot(HaveOccurred())
 
-			err = validator.ValidateTransactionCIDsRef(tx, checkedBlock.NumberU64())
+			err = validator.ValidateTransactionCIDsRef(tx, TestChainConfig, checkedBlock.NumberU64())
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(ContainSubstring(validator.EntryNotFoundErr, "eth.header_cids"))
 		})
@@ -134,10 +158,34 @@ var _ = Describe("referential integrity", Ordered, func() {
 			err := deleteEntriesFrom(tx, "ipld.blocks")
 			Expect(err).ToNot(HaveOccurred())
 
-			err = validator.ValidateTransactionCIDsRef(tx, checkedBlock.NumberU64())
+			err = validator.ValidateTransactionCIDsRef(tx, TestChainConfig, checkedBlock.NumberU64())
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(ContainSubstring(validator.EntryNotFoundErr, "ipld.blocks"))
 		})
+
+		It("Throws an error if a transaction column does not match the transaction IPLD block", func() {
+			_, err := tx.Exec(`UPDATE eth.transaction_cids SET src = $1 WHERE block_number = $2`,
+				common.HexToAddress("0x1").String(), checkedBlock.NumberU64())
+			Expect(err).ToNot(HaveOccurred())
+
+			err = validator.ValidateTransactionCIDsRef(tx, TestChainConfig, checkedBlock.NumberU64())
+			Expect(err).To(HaveOccurred())
+			var mismatchErr *validator.ColumnMismatchError
+			Expect(errors.As(err, &mismatchErr)).To(BeTrue())
+			Expect(mismatchErr.Mismatches[0].Column).To(Equal("src"))
+		})
+
+		It("Throws an error if transaction senders can't be recovered for the configured chain", func() {
+			otherChainConfig := *TestChainConfig
+			otherChainConfig.ChainID = big.NewInt(2)
+
+			err := validator.ValidateTransactionCIDsRef(tx, &otherChainConfig, checkedBlock.NumberU64())
+			Expect(err).To(HaveOccurred())
+			var mismatchErr *validator.ColumnMismatchError
+			Expect(errors.As(err, &mismatchErr)).To(BeTrue())
+			Expect(mismatchErr.Mismatches).To(HaveLen(len(checkedBlock.Transactions())))
+			Expect(mismatchErr.Mismatches[0].Column).To(Equal("src"))
+		})
 	})
 
 	Describe("ValidateReceiptCIDsRef", func() {
@@ -190,6 +238,25 @@ var _ = Describe("referential integrity", Ordered, func() {
 		})
 	})
 
+	Describe("ValidateStateCodeRef", func() {
+		It("Validates that contract code is indexed for each code hash", func() {
+			for i := uint64(startBlock); i <= checkedBlock.NumberU64(); i++ {
+				err := validator.ValidateStateCodeRef(tx, i)
+				Expect(err).ToNot(HaveOccurred())
+			}
+		})
+
+		It("Throws an error if the code for a code hash is not indexed", func() {
+			_, err := tx.Exec(`UPDATE eth.state_cids SET code_hash = $1 WHERE block_number = $2 AND NOT removed`,
+				common.HexToHash("0x1").String(), checkedBlock.NumberU64())
+			Expect(err).ToNot(HaveOccurred())
+
+			err = validator.ValidateStateCodeRef(tx, checkedBlock.NumberU64())
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring(validator.EntryNotFoundErr, "ipld.blocks"))
+		})
+	})
+
 	Describe("ValidateStorageCIDsRef", func() {
 		It("Validates referential integrity of storage_cids table", func() {
 			err := validator.ValidateStorageCIDsRef(tx, checkedBlock.NumberU64())
@@ -240,16 +307,193 @@ var _ = Describe("referential integrity", Ordered, func() {
 		})
 	})
 
+	Describe("ValidateIPLDBlocksContent", func() {
+		It("Validates the content of IPLD blocks against their CIDs", func() {
+			err := validator.ValidateIPLDBlocksContent(tx, checkedBlock.NumberU64())
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("Throws an error if IPLD block data does not match its CID", func() {
+			_, err := tx.Exec(`UPDATE ipld.blocks SET data = $1
+				WHERE key = (SELECT cid FROM eth.header_cids WHERE block_number = $2)`,
+				[]byte{0x01}, checkedBlock.NumberU64())
+			Expect(err).ToNot(HaveOccurred())
+
+			err = validator.ValidateIPLDBlocksContent(tx, checkedBlock.NumberU64())
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("does not match its hash"))
+		})
+	})
+
+	Describe("ValidateStateLeaves", func() {
+		It("Validates state_cids columns against the state trie", func() {
+			err := validator.ValidateStateLeaves(tx, api.B.IpldTrieStateDatabase, checkedBlock.NumberU64())
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("Throws an error if an indexed balance does not match the state trie", func() {
+			_, err := tx.Exec(`UPDATE eth.state_cids SET balance = balance + 1
+				WHERE block_number = $1 AND NOT removed`, checkedBlock.NumberU64())
+			Expect(err).ToNot(HaveOccurred())
+
+			err = validator.ValidateStateLeaves(tx, api.B.IpldTrieStateDatabase, checkedBlock.NumberU64())
+			Expect(err).To(HaveOccurred())
+			var mismatchErr *validator.LeafMismatchError
+			Expect(errors.As(err, &mismatchErr)).To(BeTrue())
+			Expect(mismatchErr.Mismatches[0].Field).To(Equal("balance"))
+		})
+	})
+
+	Describe("ValidateStorageLeaves", func() {
+		It("Validates storage_cids values against the storage tries", func() {
+			for i := uint64(startBlock); i <= checkedBlock.NumberU64(); i++ {
+				err := validator.ValidateStorageLeaves(tx, api.B.IpldTrieStateDatabase, i)
+				Expect(err).ToNot(HaveOccurred())
+			}
+		})
+
+		It("Throws an error if an indexed value does not match the storage trie", func() {
+			res, err := tx.Exec(`UPDATE eth.storage_cids SET val = $1 WHERE block_number = $2 AND NOT removed`,
+				[]byte{0x05}, storageBlock.NumberU64())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res.RowsAffected()).ToNot(BeZero())
+
+			err = validator.ValidateStorageLeaves(tx, api.B.IpldTrieStateDatabase, storageBlock.NumberU64())
+			Expect(err).To(HaveOccurred())
+			var mismatchErr *validator.LeafMismatchError
+			Expect(errors.As(err, &mismatchErr)).To(BeTrue())
+			Expect(mismatchErr.Mismatches[0].Field).To(Equal("val"))
+		})
+
+		It("Throws an error if an indexed removed flag does not match the storage trie", func() {
+			res, err := tx.Exec(`UPDATE eth.storage_cids SET removed = NOT removed WHERE block_number = $1`,
+				storageBlock.NumberU64())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res.RowsAffected()).ToNot(BeZero())
+
+			err = validator.ValidateStorageLeaves(tx, api.B.IpldTrieStateDatabase, storageBlock.NumberU64())
+			Expect(err).To(HaveOccurred())
+			var mismatchErr *validator.LeafMismatchError
+			Expect(errors.As(err, &mismatchErr)).To(BeTrue())
+			Expect(mismatchErr.Mismatches[0].Field).To(Equal("removed"))
+		})
+	})
+
+	Describe("CheckStateTrie", func() {
+		var (
+			stateNodes, storageNodes uint64
+			stateNode, storageNode   common.Hash // Non-root nodes to remove from ipld.blocks
+		)
+		BeforeAll(func() {
+			// Count the nodes of the generated tries, and pick a node of each kind to remove
+			root := checkedBlock.Root()
+			stateTrie, err := chain.StateCache().OpenTrie(root)
+			Expect(err).ToNot(HaveOccurred())
+			storageRoots := make(map[common.Hash]common.Hash)
+			it := stateTrie.NodeIterator(nil)
+			for it.Next(true) {
+				if it.Leaf() {
+					var account types.StateAccount
+					Expect(rlp.DecodeBytes(it.LeafBlob(), &account)).To(Succeed())
+					if account.Root != types.EmptyRootHash {
+						storageRoots[account.Root] = common.BytesToHash(it.LeafKey())
+					}
+					continue
+				}
+				stateNodes++
+				if it.Hash() != (common.Hash{}) && it.Hash() != root && stateNode == (common.Hash{}) {
+					stateNode = it.Hash()
+				}
+			}
+			Expect(it.Error()).ToNot(HaveOccurred())
+
+			for storageRoot, addrHash := range storageRoots {
+				storageTrie, err := chain.StateCache().OpenStorageTrie(root, addrHash, storageRoot)
+				Expect(err).ToNot(HaveOccurred())
+				it := storageTrie.NodeIterator(nil)
+				for it.Next(true) {
+					if it.Leaf() {
+						continue
+					}
+					storageNodes++
+					if it.Hash() != (common.Hash{}) && storageNode == (common.Hash{}) {
+						storageNode = it.Hash()
+					}
+				}
+				Expect(it.Error()).ToNot(HaveOccurred())
+			}
+			Expect(stateNode).ToNot(Equal(common.Hash{}))
+			Expect(storageNode).ToNot(Equal(common.Hash{}))
+		})
+
+		It("Reports a complete state trie", func() {
+			result, err := validator.CheckStateTrie(context.Background(), api.B, checkedBlock.Header(), false)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result.Missing).To(BeEmpty())
+			Expect(result.Complete()).To(BeTrue())
+			Expect(result.Nodes).To(Equal(stateNodes))
+		})
+
+		It("Reaches the storage tries when checking storage", func() {
+			result, err := validator.CheckStateTrie(context.Background(), api.B, checkedBlock.Header(), true)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result.Missing).To(BeEmpty())
+			Expect(result.Nodes).To(Equal(stateNodes + storageNodes))
+			Expect(result.Nodes).To(BeNumerically(">", stateNodes))
+		})
+
+		It("Reports exactly the nodes missing from ipld.blocks", func() {
+			stateCID := removeIPLDBlock(db, stateNode, cid.EthStateTrie)
+			storageCID := removeIPLDBlock(db, storageNode, cid.EthStorageTrie)
+
+			result, err := validator.CheckStateTrie(context.Background(), api.B, checkedBlock.Header(), true)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result.Complete()).To(BeFalse())
+			Expect(result.Missing).To(ConsistOf(stateCID, storageCID))
+
+			// The storage node is only reported when storage tries are checked
+			result, err = validator.CheckStateTrie(context.Background(), api.B, checkedBlock.Header(), false)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result.Missing).To(ConsistOf(stateCID))
+		})
+	})
+
 	Describe("ValidateReferentialIntegrity", func() {
 		It("Validates referential integrity of full chain", func() {
 			for i := uint64(startBlock); i <= chainLength; i++ {
-				err := validator.ValidateReferentialIntegrity(tx, i)
+				err := validator.ValidateReferentialIntegrity(tx, TestChainConfig, api.B.IpldTrieStateDatabase, i)
 				Expect(err).ToNot(HaveOccurred())
 			}
 		})
 	})
 })
 
+// removeIPLDBlock deletes every ipld.blocks entry for the trie node with the given hash, restoring
+// them once the current spec is done, and returns the node's CID.
+// The entries are deleted outside of the spec's transaction, so that they are not visible to the
+// validator's backend.
+func removeIPLDBlock(db *sqlx.DB, hash common.Hash, codec uint64) string {
+	c, err := ipfsethdb.CIDFromKeccak256(hash.Bytes(), codec)
+	Expect(err).ToNot(HaveOccurred())
+
+	var removed []struct {
+		BlockNumber string `db:"block_number"`
+		Key         string `db:"key"`
+		Data        []byte `db:"data"`
+	}
+	err = db.Select(&removed, `DELETE FROM ipld.blocks WHERE key = $1 RETURNING block_number, key, data`, c.String())
+	Expect(err).ToNot(HaveOccurred())
+	Expect(removed).ToNot(BeEmpty())
+	DeferCleanup(func() {
+		for _, block := range removed {
+			_, err := db.Exec(`INSERT INTO ipld.blocks (block_number, key, data) VALUES ($1, $2, $3)`,
+				block.BlockNumber, block.Key, block.Data)
+			Expect(err).ToNot(HaveOccurred())
+		}
+	})
+	return c.String()
+}
+
 func deleteEntriesFrom(tx *sqlx.Tx, tableName string) error {
 	pgStr := "TRUNCATE %s"
 	_, err := tx.Exec(fmt.Sprintf(pgStr, tableName))
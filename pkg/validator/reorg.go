@@ -0,0 +1,105 @@
+// VulcanizeDB
+// Copyright © 2023 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package validator
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rpc"
+	log "github.com/sirupsen/logrus"
+
+	ipldeth "github.com/cerc-io/ipld-eth-server/v5/pkg/eth"
+
+	"github.com/cerc-io/ipld-eth-db-validator/v5/pkg/prom"
+)
+
+// validatedHashes tracks the canonical block hash validated at each recent height
+type validatedHashes struct {
+	sync.Mutex
+	hashes map[uint64]common.Hash
+}
+
+func newValidatedHashes() *validatedHashes {
+	return &validatedHashes{hashes: make(map[uint64]common.Hash)}
+}
+
+func (v *validatedHashes) set(blockNum uint64, hash common.Hash) {
+	v.Lock()
+	defer v.Unlock()
+	v.hashes[blockNum] = hash
+}
+
+func (v *validatedHashes) get(blockNum uint64) (common.Hash, bool) {
+	v.Lock()
+	defer v.Unlock()
+	hash, ok := v.hashes[blockNum]
+	return hash, ok
+}
+
+// prune forgets all heights below the given one
+func (v *validatedHashes) prune(below uint64) {
+	v.Lock()
+	defer v.Unlock()
+	for blockNum := range v.hashes {
+		if blockNum < below {
+			delete(v.hashes, blockNum)
+		}
+	}
+}
+
+// checkReorgs compares the hashes validated at each height in the reorg window below the given
+// height with the current canonical hashes, and re-validates the new canonical block wherever
+// they differ. If fetching or re-validating a block fails, its height and the error are returned.
+func (s *Service) checkReorgs(ctx context.Context, api *ipldeth.PublicEthAPI, nextBlockNum uint64) (uint64, error) {
+	if s.reorgWindow == 0 {
+		return 0, nil
+	}
+	var low uint64
+	if nextBlockNum > s.reorgWindow {
+		low = nextBlockNum - s.reorgWindow
+	}
+	s.validated.prune(low)
+
+	for height := low; height < nextBlockNum; height++ {
+		validatedHash, ok := s.validated.get(height)
+		if !ok {
+			continue
+		}
+		header, err := api.B.HeaderByNumber(ctx, rpc.BlockNumber(height))
+		if err != nil {
+			return height, fmt.Errorf("failed to fetch canonical header at height %d: %w", height, err)
+		}
+		if header == nil || header.Hash() == validatedHash {
+			continue
+		}
+
+		log.WithFields(log.Fields{
+			"block":   height,
+			"oldHash": validatedHash.Hex(),
+			"newHash": header.Hash().Hex(),
+		}).Warnf("reorg detected at validated block %d, re-validating", height)
+		prom.IncReorgsDetected()
+
+		if err := s.Validate(ctx, api, height); err != nil {
+			return height, fmt.Errorf("re-validation after reorg failed: %w", err)
+		}
+	}
+	return 0, nil
+}
@@ -0,0 +1,85 @@
+package validator_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	server_mocks "github.com/cerc-io/ipld-eth-server/v5/pkg/eth/test_helpers"
+
+	"github.com/cerc-io/ipld-eth-db-validator/v5/pkg/prom"
+	"github.com/cerc-io/ipld-eth-db-validator/v5/pkg/validator"
+)
+
+// setCanonicalPgStr makes the block with the given hash the canonical one at its height
+const setCanonicalPgStr = `UPDATE eth.header_cids SET canonical = (block_hash = $2) WHERE block_number = $1`
+
+// When the canonical block changes at a validated height, the new block is re-validated and a
+// failure is handled according to the failure policy
+func TestReorgs(t *testing.T) {
+	db := setupStateValidator(t)
+	initMetrics.Do(prom.Init)
+	const validatorID = "test-reorgs"
+	clearValidator(t, db, validatorID)
+
+	reorged := server_mocks.MockBlock
+	cfg := serviceConfig(validatorID, reorged.NumberU64())
+	cfg.ReorgWindow = chainLength
+	cfg.FailurePolicy = validator.FailurePolicyRetryThenSkip
+	cfg.FailureRetries = 1
+
+	progress := make(chan uint64)
+	service, err := validator.NewService(cfg, progress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reorgsBefore := metricValue(t, "reorgs_detected")
+	wg := new(sync.WaitGroup)
+	wg.Add(1)
+	go service.Start(context.Background(), wg)
+
+	// Once a later height is validated, replace the validated block with the non-canonical mock block
+	var swapped, stopped bool
+	timeout := time.After(time.Minute)
+	for running := true; running; {
+		select {
+		case blockNum, ok := <-progress:
+			if !ok {
+				running = false
+				break
+			}
+			if !swapped {
+				if _, err := db.Exec(setCanonicalPgStr, reorged.NumberU64(), reorged.Hash().String()); err != nil {
+					t.Fatal(err)
+				}
+				swapped = true
+			}
+			if blockNum >= chainLength && !stopped {
+				service.Stop()
+				stopped = true
+			}
+		case <-timeout:
+			if !stopped {
+				service.Stop()
+			}
+			t.Fatal("timed out waiting for the validator")
+		}
+	}
+	wg.Wait()
+
+	if reorgs := metricValue(t, "reorgs_detected") - reorgsBefore; reorgs != 1 {
+		t.Fatalf("expected 1 reorg to be detected, got %v", reorgs)
+	}
+
+	// The mock block can't be replayed on the test chain, so it is validated again once under the
+	// retry-then-skip policy, and then skipped
+	var results int
+	if err := db.Get(&results, `SELECT count(*) FROM validator.block_results WHERE validator_id = $1 AND block_hash = $2`,
+		validatorID, reorged.Hash().String()); err != nil {
+		t.Fatal(err)
+	}
+	if results != 2 {
+		t.Fatalf("expected the reorged block to be validated twice, got %d results", results)
+	}
+}
@@ -0,0 +1,107 @@
+// VulcanizeDB
+// Copyright © 2023 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package validator
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/cerc-io/ipld-eth-db-validator/v5/pkg/version"
+)
+
+const insertBlockResultPgStr = `INSERT INTO validator.block_results (
+						validator_id, block_number, block_hash, state_root_ok, ref_integrity,
+						passed, duration_ms, error, validator_version
+					) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+
+// BlockResult holds the outcome of each check run on a block
+type BlockResult struct {
+	BlockNumber uint64
+	BlockHash   common.Hash
+	// Whether the block replay matched the header (state root and derived fields)
+	StateRootOK bool
+	// Whether each referential integrity check passed, by check name
+	RefIntegrity map[string]bool
+	Duration     time.Duration
+	Err          error
+}
+
+// Passed returns whether all checks on the block passed
+func (r *BlockResult) Passed() bool {
+	return r.Err == nil
+}
+
+// MarshalJSON implements json.Marshaler
+func (r *BlockResult) MarshalJSON() ([]byte, error) {
+	var errText string
+	if r.Err != nil {
+		errText = r.Err.Error()
+	}
+	return json.Marshal(struct {
+		BlockNumber  uint64          `json:"blockNumber"`
+		BlockHash    common.Hash     `json:"blockHash"`
+		Passed       bool            `json:"passed"`
+		StateRootOK  bool            `json:"stateRootOk"`
+		RefIntegrity map[string]bool `json:"refIntegrity"`
+		Duration     string          `json:"duration"`
+		Error        string          `json:"error,omitempty"`
+	}{
+		BlockNumber:  r.BlockNumber,
+		BlockHash:    r.BlockHash,
+		Passed:       r.Passed(),
+		StateRootOK:  r.StateRootOK,
+		RefIntegrity: r.RefIntegrity,
+		Duration:     r.Duration.String(),
+		Error:        errText,
+	})
+}
+
+func newBlockResult(blockNumber uint64, blockHash common.Hash) *BlockResult {
+	return &BlockResult{
+		BlockNumber: blockNumber,
+		BlockHash:   blockHash,
+	}
+}
+
+// recordBlockResult writes the result of validating a block to the validator.block_results table
+func recordBlockResult(db *sqlx.DB, validatorID string, result *BlockResult) error {
+	refIntegrity, err := json.Marshal(result.RefIntegrity)
+	if err != nil {
+		return err
+	}
+	var errText *string
+	if result.Err != nil {
+		text := result.Err.Error()
+		errText = &text
+	}
+
+	_, err = db.Exec(insertBlockResultPgStr,
+		validatorID,
+		result.BlockNumber,
+		result.BlockHash.String(),
+		result.StateRootOK,
+		string(refIntegrity),
+		result.Passed(),
+		result.Duration.Milliseconds(),
+		errText,
+		version.VersionWithMeta,
+	)
+	return err
+}
@@ -0,0 +1,105 @@
+package validator_test
+
+import (
+	"database/sql"
+	"encoding/json"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/cerc-io/ipld-eth-db-validator/v5/pkg/validator"
+	"github.com/cerc-io/ipld-eth-db-validator/v5/pkg/version"
+)
+
+type blockResultRow struct {
+	BlockHash    string         `db:"block_hash"`
+	StateRootOK  bool           `db:"state_root_ok"`
+	RefIntegrity string         `db:"ref_integrity"`
+	Passed       bool           `db:"passed"`
+	Error        sql.NullString `db:"error"`
+	Version      string         `db:"validator_version"`
+}
+
+const getBlockResultPgStr = `SELECT block_hash, state_root_ok, ref_integrity, passed, error, validator_version
+	FROM validator.block_results WHERE validator_id = $1 AND block_number = $2`
+
+// A row is recorded in validator.block_results for each validated block, passing or not
+func TestBlockResults(t *testing.T) {
+	db := setupStateValidator(t)
+	const validatorID = "test-results"
+	clearValidator(t, db, validatorID)
+
+	// Block 5 fails the transaction_cids check
+	const passingBlock, failingBlock = 4, 5
+	if _, err := db.Exec(`UPDATE eth.transaction_cids SET src = $1 WHERE block_number = $2`,
+		common.HexToAddress("0x1").Hex(), failingBlock); err != nil {
+		t.Fatal(err)
+	}
+	cfg := serviceConfig(validatorID, passingBlock)
+	cfg.ToBlock = failingBlock
+	cfg.FailurePolicy = validator.FailurePolicySkip
+	service, _ := runService(t, cfg, 0)
+	summary := service.Summary()
+	if len(summary.Failures) != 1 || summary.Failures[0].BlockNumber != failingBlock {
+		t.Fatalf("expected only block %d to fail, got %s", failingBlock, &summary)
+	}
+
+	getRow := func(t *testing.T, blockNum uint64) (blockResultRow, map[string]bool) {
+		var rows []blockResultRow
+		if err := db.Select(&rows, getBlockResultPgStr, validatorID, blockNum); err != nil {
+			t.Fatal(err)
+		}
+		if len(rows) != 1 {
+			t.Fatalf("expected 1 result for block %d, got %d", blockNum, len(rows))
+		}
+		var hash string
+		if err := db.Get(&hash, `SELECT block_hash FROM eth.header_cids WHERE block_number = $1`, blockNum); err != nil {
+			t.Fatal(err)
+		}
+		row := rows[0]
+		if row.BlockHash != hash {
+			t.Fatalf("expected block hash %s, got %s", hash, row.BlockHash)
+		}
+		if row.Version != version.VersionWithMeta {
+			t.Fatalf("expected validator version %s, got %s", version.VersionWithMeta, row.Version)
+		}
+		if !row.StateRootOK {
+			t.Fatalf("expected the state root of block %d to be verified", blockNum)
+		}
+		var refIntegrity map[string]bool
+		if err := json.Unmarshal([]byte(row.RefIntegrity), &refIntegrity); err != nil {
+			t.Fatal(err)
+		}
+		return row, refIntegrity
+	}
+
+	t.Run("Passing block", func(t *testing.T) {
+		row, refIntegrity := getRow(t, passingBlock)
+		if !row.Passed || row.Error.Valid {
+			t.Fatalf("expected block %d to pass, got error %q", passingBlock, row.Error.String)
+		}
+		if len(refIntegrity) == 0 {
+			t.Fatal("expected referential integrity results")
+		}
+		for name, ok := range refIntegrity {
+			if !ok {
+				t.Fatalf("expected check %s to pass", name)
+			}
+		}
+	})
+
+	t.Run("Failing block", func(t *testing.T) {
+		row, refIntegrity := getRow(t, failingBlock)
+		if row.Passed {
+			t.Fatalf("expected block %d to fail", failingBlock)
+		}
+		if expected := summary.Failures[0].Err.Error(); row.Error.String != expected {
+			t.Fatalf("expected error %q, got %q", expected, row.Error.String)
+		}
+		for name, ok := range refIntegrity {
+			if ok != (name != "transaction_cids") {
+				t.Fatalf("expected only the transaction_cids check to fail, got %v", refIntegrity)
+			}
+		}
+	})
+}
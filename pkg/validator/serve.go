@@ -0,0 +1,81 @@
+// VulcanizeDB
+// Copyright © 2023 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package validator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/ethereum/go-ethereum/rpc"
+	log "github.com/sirupsen/logrus"
+)
+
+var errAPIHTTP = errors.New("can't start http server for validator API")
+
+// apiShutdownTimeout limits how long in-flight API requests are waited for when the service stops
+const apiShutdownTimeout = 30 * time.Second
+
+// ServeAPI starts serving the validator API over HTTP at the given address.
+// JSON-RPC requests are served at the root path, and the service status at /status.
+// It must be called before Start; the server is shut down when the service stops, before the
+// backend used to serve requests is closed.
+func (s *Service) ServeAPI(addr string) error {
+	rpcServer := rpc.NewServer()
+	if err := rpcServer.RegisterName(APIName, NewPublicValidatorAPI(s)); err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/", rpcServer)
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(s.Status()); err != nil {
+			log.Errorf("error writing status response: %s", err)
+		}
+	})
+	srv := &http.Server{
+		Addr:    addr,
+		Handler: mux,
+	}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.
+				WithError(err).
+				WithField("module", "api").
+				WithField("addr", addr).
+				Fatal(errAPIHTTP)
+		}
+	}()
+	s.apiServer = srv
+	return nil
+}
+
+// shutdownAPI stops the API server, if it was started, waiting for in-flight requests to finish
+func (s *Service) shutdownAPI() {
+	if s.apiServer == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), apiShutdownTimeout)
+	defer cancel()
+	if err := s.apiServer.Shutdown(ctx); err != nil {
+		log.Errorf("error shutting down validator API server: %s", err)
+		s.apiServer.Close()
+	}
+}
@@ -0,0 +1,64 @@
+// VulcanizeDB
+// Copyright © 2023 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package validator
+
+import (
+	"fmt"
+)
+
+// BlockFailure records a block which failed validation
+type BlockFailure struct {
+	BlockNumber uint64
+	Err         error
+}
+
+// Summary describes the outcome of a validation run
+type Summary struct {
+	// Configured range; ToBlock is zero if the range is unbounded
+	FromBlock, ToBlock uint64
+	// Number of blocks validated, and the most recent height validated
+	Validated, LastValidated uint64
+	Failures                 []BlockFailure
+}
+
+// Complete returns whether every block in a bounded range was validated
+func (s *Summary) Complete() bool {
+	return s.ToBlock != 0 && s.ToBlock >= s.FromBlock && s.Validated == s.ToBlock-s.FromBlock+1
+}
+
+// Passed returns whether no block failed validation, and for a bounded range, whether all blocks
+// in it were validated
+func (s *Summary) Passed() bool {
+	if len(s.Failures) != 0 {
+		return false
+	}
+	return s.ToBlock == 0 || s.Complete()
+}
+
+func (s *Summary) String() string {
+	var target string
+	if s.ToBlock != 0 {
+		target = fmt.Sprintf("%d-%d", s.FromBlock, s.ToBlock)
+	} else {
+		target = fmt.Sprintf("%d onwards", s.FromBlock)
+	}
+	str := fmt.Sprintf("blocks %s: %d validated, %d failed", target, s.Validated, len(s.Failures))
+	if s.Validated != 0 {
+		str += fmt.Sprintf(", validated through block %d", s.LastValidated)
+	}
+	return str
+}
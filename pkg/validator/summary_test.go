@@ -0,0 +1,33 @@
+package validator_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cerc-io/ipld-eth-db-validator/v5/pkg/validator"
+)
+
+func TestSummary(t *testing.T) {
+	testCases := []struct {
+		name    string
+		summary validator.Summary
+		passed  bool
+	}{
+		{"complete range", validator.Summary{FromBlock: 5, ToBlock: 9, Validated: 5, LastValidated: 9}, true},
+		{"incomplete range", validator.Summary{FromBlock: 5, ToBlock: 9, Validated: 3, LastValidated: 7}, false},
+		{"unbounded", validator.Summary{FromBlock: 5, Validated: 3, LastValidated: 7}, true},
+		{"inverted range", validator.Summary{FromBlock: 10, ToBlock: 9}, false},
+		{"failed", validator.Summary{
+			FromBlock: 5, ToBlock: 9, Validated: 2, LastValidated: 6,
+			Failures: []validator.BlockFailure{{BlockNumber: 7, Err: errors.New("bad block")}},
+		}, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if passed := tc.summary.Passed(); passed != tc.passed {
+				t.Fatalf("expected Passed() = %v, got %v (%s)", tc.passed, passed, &tc.summary)
+			}
+		})
+	}
+}
@@ -0,0 +1,340 @@
+// VulcanizeDB
+// Copyright © 2023 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package validator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ipfs/go-cid"
+	"github.com/jmoiron/sqlx"
+	log "github.com/sirupsen/logrus"
+
+	ipfsethdb "github.com/cerc-io/ipfs-ethdb/v5/postgres/v0"
+	ipldeth "github.com/cerc-io/ipld-eth-server/v5/pkg/eth"
+	ipldstate "github.com/cerc-io/ipld-eth-statedb/trie_by_cid/state"
+	ipldtrie "github.com/cerc-io/ipld-eth-statedb/trie_by_cid/trie"
+
+	"github.com/cerc-io/ipld-eth-db-validator/v5/pkg/prom"
+	"github.com/cerc-io/ipld-eth-db-validator/v5/pkg/version"
+)
+
+// Table holding the results of state trie checks, created in the validator schema
+var trieCheckSchema = []string{
+	`CREATE TABLE IF NOT EXISTS validator.trie_checks (
+		id                BIGSERIAL PRIMARY KEY,
+		validator_id      TEXT NOT NULL,
+		block_number      BIGINT NOT NULL,
+		block_hash        VARCHAR(66) NOT NULL,
+		state_root        VARCHAR(66) NOT NULL,
+		storage           BOOLEAN NOT NULL,
+		nodes             BIGINT NOT NULL,
+		missing           JSONB NOT NULL,
+		complete          BOOLEAN NOT NULL,
+		duration_ms       BIGINT NOT NULL,
+		validator_version TEXT NOT NULL,
+		checked_at        TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+	)`,
+	`CREATE INDEX IF NOT EXISTS trie_checks_block_idx ON validator.trie_checks (block_number, block_hash)`,
+}
+
+const insertTrieCheckPgStr = `INSERT INTO validator.trie_checks (
+						validator_id, block_number, block_hash, state_root, storage,
+						nodes, missing, complete, duration_ms, validator_version
+					) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+
+// TrieCheckResult holds the outcome of checking that the whole state trie at a block is indexed
+type TrieCheckResult struct {
+	BlockNumber uint64
+	BlockHash   common.Hash
+	StateRoot   common.Hash
+	// Whether storage tries were checked along with the state trie
+	Storage bool
+	// Number of trie nodes found
+	Nodes uint64
+	// CIDs of the trie nodes missing from ipld.blocks
+	Missing  []string
+	Duration time.Duration
+}
+
+// Complete returns whether every node of the checked tries was found
+func (r *TrieCheckResult) Complete() bool {
+	return len(r.Missing) == 0
+}
+
+// MarshalJSON implements json.Marshaler
+func (r *TrieCheckResult) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		BlockNumber uint64      `json:"blockNumber"`
+		BlockHash   common.Hash `json:"blockHash"`
+		StateRoot   common.Hash `json:"stateRoot"`
+		Storage     bool        `json:"storage"`
+		Complete    bool        `json:"complete"`
+		Nodes       uint64      `json:"nodes"`
+		Missing     []string    `json:"missing"`
+		Duration    string      `json:"duration"`
+	}{
+		BlockNumber: r.BlockNumber,
+		BlockHash:   r.BlockHash,
+		StateRoot:   r.StateRoot,
+		Storage:     r.Storage,
+		Complete:    r.Complete(),
+		Nodes:       r.Nodes,
+		Missing:     r.Missing,
+		Duration:    r.Duration.String(),
+	})
+}
+
+// CheckStateTrie walks the whole state trie from the header's state root, and optionally every
+// storage trie, and reports each node missing from ipld.blocks.
+// The tries are opened and iterated with the IPLD trie state database. When a node is missing, the
+// iteration resumes after the subtrie rooted at it, so that the whole trie is still checked.
+// Each node costs a database round trip, so on large chains a full walk makes hundreds of millions
+// of queries, and many more with storage; it is meant to run rarely and in the background.
+func CheckStateTrie(ctx context.Context, b *ipldeth.Backend, header *types.Header, storage bool) (*TrieCheckResult, error) {
+	start := time.Now()
+	w := &trieWalker{
+		ctx:          ctx,
+		stateDB:      b.IpldTrieStateDatabase,
+		db:           b.EthDB,
+		storage:      storage,
+		storageRoots: make(map[common.Hash]bool),
+		result: &TrieCheckResult{
+			BlockNumber: header.Number.Uint64(),
+			BlockHash:   header.Hash(),
+			StateRoot:   header.Root,
+			Storage:     storage,
+		},
+	}
+	if header.Root != types.EmptyRootHash {
+		if err := w.walkState(header.Root); err != nil {
+			return nil, err
+		}
+	}
+	w.result.Duration = time.Since(start)
+	return w.result, nil
+}
+
+// checkTrieAt starts checking the state trie at the given height in the background, unless the
+// previous check is still running
+func (s *Service) checkTrieAt(ctx context.Context, api *ipldeth.PublicEthAPI, blockNum uint64) {
+	if !s.trieCheckRunning.CompareAndSwap(false, true) {
+		log.Warnf("skipping state trie check at block %d, previous check still running", blockNum)
+		return
+	}
+	s.trieChecks.Add(1)
+	go func() {
+		defer s.trieChecks.Done()
+		defer s.trieCheckRunning.Store(false)
+		if _, err := s.checkStateTrie(ctx, api, blockNum, s.trieCheckStorage); err != nil {
+			log.Errorf("failed to check state trie at block %d: %s", blockNum, err)
+		}
+	}()
+}
+
+// checkStateTrie checks the state trie of the canonical block at the given height, and records
+// the result
+func (s *Service) checkStateTrie(ctx context.Context, api *ipldeth.PublicEthAPI, blockNum uint64, storage bool) (*TrieCheckResult, error) {
+	header, err := api.B.HeaderByNumber(ctx, rpc.BlockNumber(blockNum))
+	if err != nil {
+		return nil, err
+	}
+	if header == nil {
+		return nil, &MissingBlockError{blockNum}
+	}
+
+	log.Infof("checking state trie at block %d", blockNum)
+	result, err := CheckStateTrie(ctx, api.B, header, storage)
+	if err != nil {
+		return nil, err
+	}
+	if err := recordTrieCheck(s.db, s.validatorID, result); err != nil {
+		log.Errorf("failed to record state trie check at block %d: %s", blockNum, err)
+	}
+	if result.Complete() {
+		log.Infof("state trie complete at block %d (%d nodes)", blockNum, result.Nodes)
+		prom.SetLastCompleteStateTrie(float64(blockNum))
+	} else {
+		log.Errorf("state trie at block %d is missing %d nodes", blockNum, len(result.Missing))
+	}
+	return result, nil
+}
+
+// trieWalker visits every node of a trie reachable from the root
+type trieWalker struct {
+	ctx     context.Context
+	stateDB ipldstate.Database
+	// Database backing the trie state database, used to tell missing nodes from failed reads
+	db      ethdb.KeyValueReader
+	storage bool
+	// Storage roots already walked, as contracts can share storage tries
+	storageRoots map[common.Hash]bool
+	result       *TrieCheckResult
+}
+
+// walkState walks the state trie with the given root
+func (w *trieWalker) walkState(root common.Hash) error {
+	t, err := w.stateDB.OpenTrie(root)
+	if err != nil {
+		return w.recordMissing(root, cid.EthStateTrie, err)
+	}
+	return w.walk(t, cid.EthStateTrie)
+}
+
+// walkStorage walks the storage trie of an account, unless it is empty or already walked
+func (w *trieWalker) walkStorage(addrHash, root common.Hash) error {
+	if root == types.EmptyRootHash || w.storageRoots[root] {
+		return nil
+	}
+	w.storageRoots[root] = true
+	t, err := w.stateDB.OpenStorageTrie(w.result.StateRoot, addrHash, root)
+	if err != nil {
+		return w.recordMissing(root, cid.EthStorageTrie, err)
+	}
+	return w.walk(t, cid.EthStorageTrie)
+}
+
+// walk iterates over every node of the trie, walking the storage trie of each account leaf if
+// storage tries are being checked. An iterator stops at the first node it can't resolve, so a
+// missing node is recorded and a new iterator is started after the subtrie rooted at it.
+func (w *trieWalker) walk(t ipldstate.Trie, codec uint64) error {
+	var start, passed []byte
+	for {
+		it := t.NodeIterator(start)
+		for first := true; it.Next(true); first = false {
+			if err := w.ctx.Err(); err != nil {
+				return err
+			}
+			// Seeking to an odd length path passes over the node at that path, without yielding it
+			if first && passed != nil && len(it.Path()) > len(passed) && bytes.HasPrefix(it.Path(), passed) {
+				w.result.Nodes++
+			}
+			if !it.Leaf() {
+				w.result.Nodes++
+				continue
+			}
+			if codec != cid.EthStateTrie || !w.storage {
+				continue
+			}
+			var account types.StateAccount
+			if err := rlp.DecodeBytes(it.LeafBlob(), &account); err != nil {
+				return fmt.Errorf("invalid account: %w", err)
+			}
+			if err := w.walkStorage(common.BytesToHash(it.LeafKey()), account.Root); err != nil {
+				return err
+			}
+		}
+
+		err := it.Error()
+		if err == nil {
+			return nil
+		}
+		var missing *ipldtrie.MissingNodeError
+		if !errors.As(err, &missing) {
+			return err
+		}
+		if err := w.recordMissing(missing.NodeHash, codec, err); err != nil {
+			return err
+		}
+		next, ok := nextSubtrie(missing.Path)
+		if !ok {
+			return nil
+		}
+		start, passed = nibblesToKey(next), nil
+		if len(next)%2 == 1 {
+			passed = next
+		}
+	}
+}
+
+// recordMissing records the node with the given hash as missing if it is not in ipld.blocks.
+// Otherwise the error resolving it was a failure to read it, and is returned.
+func (w *trieWalker) recordMissing(hash common.Hash, codec uint64, resolveErr error) error {
+	c, err := ipfsethdb.CIDFromKeccak256(hash.Bytes(), codec)
+	if err != nil {
+		return err
+	}
+	has, err := w.db.Has(c.Bytes())
+	if err != nil {
+		return err
+	}
+	if has {
+		return resolveErr
+	}
+	w.result.Missing = append(w.result.Missing, c.String())
+	return nil
+}
+
+// nextSubtrie returns the nibble path of the first subtrie following the one at the given path,
+// or false if the given subtrie is the last one in the trie
+func nextSubtrie(path []byte) ([]byte, bool) {
+	next := common.CopyBytes(path)
+	for i := len(next) - 1; i >= 0; i-- {
+		if next[i] < 15 {
+			next[i]++
+			return next[:i+1], true
+		}
+	}
+	return nil, false
+}
+
+// nibblesToKey packs a nibble path into a key, padding a path of odd length with a zero nibble
+func nibblesToKey(nibbles []byte) []byte {
+	key := make([]byte, (len(nibbles)+1)/2)
+	for i, n := range nibbles {
+		if i%2 == 0 {
+			key[i/2] = n << 4
+		} else {
+			key[i/2] |= n
+		}
+	}
+	return key
+}
+
+// recordTrieCheck writes the result of a trie check to the validator.trie_checks table
+func recordTrieCheck(db *sqlx.DB, validatorID string, result *TrieCheckResult) error {
+	missing := result.Missing
+	if missing == nil {
+		missing = []string{}
+	}
+	missingJSON, err := json.Marshal(missing)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(insertTrieCheckPgStr,
+		validatorID,
+		result.BlockNumber,
+		result.BlockHash.String(),
+		result.StateRoot.String(),
+		result.Storage,
+		result.Nodes,
+		string(missingJSON),
+		result.Complete(),
+		result.Duration.Milliseconds(),
+		version.VersionWithMeta,
+	)
+	return err
+}
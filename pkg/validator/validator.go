@@ -22,20 +22,26 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/cerc-io/plugeth-statediff"
 	"github.com/cerc-io/plugeth-statediff/indexer/database/sql/postgres"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/consensus/beacon"
 	"github.com/ethereum/go-ethereum/consensus/clique"
 	"github.com/ethereum/go-ethereum/consensus/ethash"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/trie"
 	"github.com/jmoiron/sqlx"
 	log "github.com/sirupsen/logrus"
 
@@ -53,17 +59,34 @@ var (
 )
 
 type Service struct {
-	db *sqlx.DB
-
-	chainConfig           *params.ChainConfig
-	ethClient             *rpc.Client
-	blockNum, trail       uint64
-	retryInterval         time.Duration
-	stateDiffMissingBlock bool
-	stateDiffTimeout      time.Duration
-
+	db          *sqlx.DB
+	api         *ipldeth.PublicEthAPI
+	validatorID string
+
+	ethClient          *rpc.Client
+	blockNum, trail    uint64
+	toBlock            uint64
+	workers            uint64
+	allForks           bool
+	reorgWindow        uint64
+	retryInterval      time.Duration
+	stateDiffTimeout   time.Duration
+	missingBlockPolicy MissingBlockPolicy
+	failurePolicy      FailurePolicy
+	failureRetries     uint
+	trieCheckInterval  uint64
+	trieCheckStorage   bool
+
+	apiServer    *http.Server
 	quitChan     chan bool
+	doneChan     chan struct{}
 	progressChan chan<- uint64
+	summary      Summary
+	validated    *validatedHashes
+	status       status
+
+	trieChecks       sync.WaitGroup
+	trieCheckRunning atomic.Bool
 }
 
 func NewService(cfg *Config, progressChan chan<- uint64) (*Service, error) {
@@ -76,61 +99,170 @@ func NewService(cfg *Config, progressChan chan<- uint64) (*Service, error) {
 		prom.RegisterDBCollector(cfg.DBConfig.DatabaseName, db)
 	}
 
+	if err := createValidatorTables(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error creating validator tables: %w", err)
+	}
+
+	api, err := EthAPI(context.Background(), db, cfg.ChainConfig)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	if cfg.Client == nil && api.B.Config.ChainConfig.ShanghaiTime != nil {
+		log.Warn("no statediffing endpoint is configured to fetch withdrawals from; " +
+			"post-Shanghai blocks with withdrawals will fail validation")
+	}
+
+	// Resume after the last checkpoint, unless configured to start further ahead. A bounded range is
+	// always validated in full, so that an audit can re-check blocks below the checkpoint.
+	fromBlock := cfg.FromBlock
+	if cfg.ToBlock != 0 {
+		log.Infof("validating range %d-%d, the checkpoint of validator %s is not used or updated",
+			cfg.FromBlock, cfg.ToBlock, cfg.ValidatorID)
+	} else if !cfg.IgnoreCheckpoint {
+		checkpoint, ok, err := loadCheckpoint(db, cfg.ValidatorID)
+		if err != nil {
+			// The backend closes the DB along with itself
+			api.B.Close()
+			return nil, fmt.Errorf("error loading checkpoint: %w", err)
+		}
+		if ok && checkpoint+1 > fromBlock {
+			log.Infof("resuming validator %s from checkpoint at block %d", cfg.ValidatorID, checkpoint)
+			fromBlock = checkpoint + 1
+		}
+	}
+
 	return &Service{
-		db:                    db,
-		chainConfig:           cfg.ChainConfig,
-		ethClient:             cfg.Client,
-		blockNum:              cfg.FromBlock,
-		trail:                 cfg.Trail,
-		retryInterval:         cfg.RetryInterval,
-		stateDiffMissingBlock: cfg.StateDiffMissingBlock,
-		stateDiffTimeout:      cfg.StateDiffTimeout,
-		quitChan:              make(chan bool),
-		progressChan:          progressChan,
+		db:                 db,
+		api:                api,
+		validatorID:        cfg.ValidatorID,
+		ethClient:          cfg.Client,
+		blockNum:           fromBlock,
+		toBlock:            cfg.ToBlock,
+		trail:              cfg.Trail,
+		workers:            cfg.Workers,
+		allForks:           cfg.AllForks,
+		reorgWindow:        cfg.ReorgWindow,
+		retryInterval:      cfg.RetryInterval,
+		stateDiffTimeout:   cfg.StateDiffTimeout,
+		missingBlockPolicy: cfg.MissingBlockPolicy,
+		failurePolicy:      cfg.FailurePolicy,
+		failureRetries:     cfg.FailureRetries,
+		trieCheckInterval:  cfg.TrieCheckInterval,
+		trieCheckStorage:   cfg.TrieCheckStorage,
+		quitChan:           make(chan bool),
+		doneChan:           make(chan struct{}),
+		progressChan:       progressChan,
+		summary:            Summary{FromBlock: cfg.FromBlock, ToBlock: cfg.ToBlock},
+		validated:          newValidatedHashes(),
 	}, nil
 }
 
-// Start is used to begin the service
+// Start is used to begin the service.
+// It runs until stopped, until a block fails validation under the halt failure policy, or until
+// the end of a bounded range is validated; Done is closed when it returns.
 func (s *Service) Start(ctx context.Context, wg *sync.WaitGroup) {
 	defer wg.Done()
-
-	api, err := EthAPI(ctx, s.db, s.chainConfig)
-	if err != nil {
-		log.Fatal(err)
-		return
-	}
+	defer close(s.doneChan)
+
+	api := s.api
+	ctx, cancel := context.WithCancel(ctx)
+	defer func() {
+		// Stop serving API requests and any state trie check still running before closing the backend
+		s.shutdownAPI()
+		cancel()
+		s.trieChecks.Wait()
+		if s.progressChan != nil {
+			close(s.progressChan)
+		}
+		if err := api.B.Close(); err != nil {
+			log.Errorf("error closing backend: %s", err)
+		}
+	}()
 
 	nextBlockNum := s.blockNum
 	var delay time.Duration
 	for {
+		s.status.setNextBlock(nextBlockNum)
+		if s.toBlock != 0 && nextBlockNum > s.toBlock {
+			log.Infof("reached end of validation range %d-%d", s.blockNum, s.toBlock)
+			return
+		}
+
 		select {
 		case <-s.quitChan:
 			log.Info("stopping ipld-eth-db-validator process")
-			if s.progressChan != nil {
-				close(s.progressChan)
-			}
-			if err := api.B.Close(); err != nil {
-				log.Errorf("error closing backend: %s", err)
-			}
 			return
 		case <-time.After(delay):
-			err := s.Validate(ctx, api, nextBlockNum)
-			// If chain is not synced, wait for trail to catch up before trying again
-			if notsynced, ok := err.(*ChainNotSyncedError); ok {
-				delay = s.retryInterval
-				log.Infof("waiting %v for chain to advance to block %d (head is at %d)",
-					delay, nextBlockNum+s.trail, notsynced.Head)
-				continue
-			}
-			if err != nil {
-				log.Fatal(err)
+			var stop bool
+			nextBlockNum, delay, stop = s.advance(ctx, api, nextBlockNum)
+			if stop {
 				return
 			}
-			prom.SetLastValidatedBlock(float64(nextBlockNum))
+		}
+	}
+}
+
+// advance validates a batch of blocks starting at the given height and handles the results in
+// order of height, only advancing past a height once all lower heights in the batch are done.
+// It returns the next height to validate, the delay before doing so, and whether to stop.
+func (s *Service) advance(ctx context.Context, api *ipldeth.PublicEthAPI, nextBlockNum uint64) (uint64, time.Duration, bool) {
+	if height, err := s.checkReorgs(ctx, api, nextBlockNum); err != nil {
+		stop, err := s.handleFailure(ctx, api, height, err)
+		if stop {
+			return nextBlockNum, 0, true
+		}
+		if err != nil {
+			// The checkpoint is already past the re-validated block
+			s.reportFailure(height, err)
+		}
+	}
+
+	errs, err := s.validateBatch(ctx, api, nextBlockNum)
+	if err != nil {
+		// If chain is not synced, wait for trail to catch up before trying again
+		if notsynced, ok := err.(*ChainNotSyncedError); ok {
+			log.Infof("waiting %v for chain to advance to block %d (head is at %d)",
+				s.retryInterval, nextBlockNum+s.trail, notsynced.Head)
+			return nextBlockNum, s.retryInterval, false
+		}
+		if s.failurePolicy == FailurePolicyHalt {
+			s.markFailed(nextBlockNum, err)
+			return nextBlockNum, 0, true
+		}
+		log.Errorf("failed to fetch head block: %s", err)
+		return nextBlockNum, s.retryInterval, false
+	}
+
+	for _, err := range errs {
+		// The head may have moved back since the batch was started
+		if _, ok := err.(*ChainNotSyncedError); ok {
+			return nextBlockNum, s.retryInterval, false
+		}
+		var missing *MissingBlockError
+		if errors.As(err, &missing) && s.missingBlockPolicy == MissingBlockPolicyRecordAndSkip {
+			s.markSkipped(nextBlockNum, err)
 			nextBlockNum++
-			delay = 0
+			continue
+		}
+		if err != nil {
+			var stop bool
+			if stop, err = s.handleFailure(ctx, api, nextBlockNum, err); stop {
+				return nextBlockNum, 0, true
+			}
+		}
+		if err != nil {
+			s.markSkipped(nextBlockNum, err)
+		} else {
+			s.markValidated(nextBlockNum)
+			if s.trieCheckInterval != 0 && nextBlockNum%s.trieCheckInterval == 0 {
+				s.checkTrieAt(ctx, api, nextBlockNum)
+			}
 		}
+		nextBlockNum++
 	}
+	return nextBlockNum, 0, false
 }
 
 // Stop is used to gracefully stop the service
@@ -138,62 +270,343 @@ func (s *Service) Stop() {
 	close(s.quitChan)
 }
 
+// Done returns a channel which is closed once the service has stopped running
+func (s *Service) Done() <-chan struct{} {
+	return s.doneChan
+}
+
+// Summary returns the outcome of the validation run. It should only be called once Done is closed.
+func (s *Service) Summary() Summary {
+	return s.summary
+}
+
+// Validate validates the block(s) at the given height as part of the service's run. Blocks
+// validated on demand through the API are not tracked for reorgs.
 func (s *Service) Validate(ctx context.Context, api *ipldeth.PublicEthAPI, idxBlockNum uint64) error {
+	results, err := s.validateHeight(ctx, api, idxBlockNum)
+	if len(results) != 0 {
+		// Remember the canonical hash, so that reorgs below the validated height can be detected
+		s.validated.set(idxBlockNum, results[0].BlockHash)
+	}
+	return err
+}
+
+// validateHeight validates the block(s) at the given height, returning the result for each block.
+// If the blocks can't be fetched, the failure is recorded as a result without a block hash, unless
+// the height can't be validated yet or the service is stopping.
+func (s *Service) validateHeight(ctx context.Context, api *ipldeth.PublicEthAPI, idxBlockNum uint64) ([]*BlockResult, error) {
 	log.Debugf("validating block %d", idxBlockNum)
+	blocks, err := s.blocksAtHeight(ctx, api, idxBlockNum)
+	if err != nil {
+		if _, ok := err.(*ChainNotSyncedError); !ok && ctx.Err() == nil {
+			s.recordFailure(idxBlockNum, err)
+		}
+		return nil, err
+	}
+
+	return s.validateBlocks(ctx, api, idxBlockNum, blocks)
+}
+
+// blocksAtHeight fetches the canonical block at the given height, followed by any non-canonical
+// blocks if all forks are validated
+func (s *Service) blocksAtHeight(ctx context.Context, api *ipldeth.PublicEthAPI, idxBlockNum uint64) ([]*types.Block, error) {
 	headBlockNum, err := fetchHeadBlockNumber(ctx, api)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Check if block at requested height can be validated
 	if idxBlockNum+s.trail > headBlockNum {
-		return &ChainNotSyncedError{headBlockNum}
+		return nil, &ChainNotSyncedError{headBlockNum}
 	}
 
 	blockToBeValidated, err := api.B.BlockByNumber(ctx, rpc.BlockNumber(idxBlockNum))
 	if err != nil {
 		log.Errorf("failed to fetch block at height %d", idxBlockNum)
-		return err
+		return nil, err
 	}
 
-	// Make a writeStateDiffAt call if block not found in the db
 	if blockToBeValidated == nil {
-		return s.writeStateDiffAt(idxBlockNum)
+		blockToBeValidated, err = s.applyMissingBlockPolicy(ctx, api, idxBlockNum)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	err = ValidateBlock(blockToBeValidated, api.B, idxBlockNum)
+	blocks := []*types.Block{blockToBeValidated}
+	if s.allForks {
+		forks, err := nonCanonicalBlocks(ctx, s.db, api, idxBlockNum, blockToBeValidated.Hash())
+		if err != nil {
+			log.Errorf("failed to fetch non-canonical blocks at height %d", idxBlockNum)
+			return nil, err
+		}
+		blocks = append(blocks, forks...)
+	}
+	return blocks, nil
+}
+
+// recordFailure records a failure to validate the height, when there is no block to record it for
+func (s *Service) recordFailure(blockNum uint64, err error) {
+	result := newBlockResult(blockNum, common.Hash{})
+	result.Err = err
+	if err := recordBlockResult(s.db, s.validatorID, result); err != nil {
+		log.Errorf("failed to record result for block %d: %s", blockNum, err)
+	}
+}
+
+// validateBlocks runs the block replay checks on each of the given blocks at a height, and the
+// referential integrity checks on the data at that height, recording a result for each block.
+// It returns the results and the first error encountered.
+func (s *Service) validateBlocks(ctx context.Context, api *ipldeth.PublicEthAPI, blockNum uint64, blocks []*types.Block) ([]*BlockResult, error) {
+	// Referential integrity is checked across all data at the height, so only needs doing once
+	start := time.Now()
+	refIntegrity, refErr := checkReferentialIntegrity(s.db, api.B, blockNum)
+	refDuration := time.Since(start)
+
+	var results []*BlockResult
+	var firstErr error
+	for _, block := range blocks {
+		start := time.Now()
+		result := replayBlock(ctx, api.B, s.ethClient, block)
+		result.RefIntegrity = refIntegrity
+		if result.Err == nil {
+			result.Err = refErr
+		}
+		result.Duration = time.Since(start) + refDuration
+
+		if err := recordBlockResult(s.db, s.validatorID, result); err != nil {
+			log.Errorf("failed to record validation result for block %d: %s", blockNum, err)
+		}
+		if firstErr == nil {
+			firstErr = result.Err
+		}
+		results = append(results, result)
+	}
+	return results, firstErr
+}
+
+// CheckBlock runs the block replay and referential integrity checks on the given block once,
+// without recording the result. The client is used to fetch withdrawals, and may be nil.
+func CheckBlock(ctx context.Context, db *sqlx.DB, b *ipldeth.Backend, client *rpc.Client, block *types.Block) *BlockResult {
+	start := time.Now()
+	result := replayBlock(ctx, b, client, block)
+	refIntegrity, refErr := checkReferentialIntegrity(db, b, block.NumberU64())
+	result.RefIntegrity = refIntegrity
+	if result.Err == nil {
+		result.Err = refErr
+	}
+	result.Duration = time.Since(start)
+	return result
+}
+
+// replayBlock runs the block replay checks on the given block, fetching its withdrawals with the
+// given client if needed
+func replayBlock(ctx context.Context, b *ipldeth.Backend, client *rpc.Client, block *types.Block) *BlockResult {
+	blockNum := block.NumberU64()
+	result := newBlockResult(blockNum, block.Hash())
+	logger := log.WithField("hash", block.Hash().Hex())
+
+	block, err := withWithdrawals(ctx, client, block)
+	if err == nil {
+		err = ValidateBlock(block, b, blockNum)
+	}
 	if err != nil {
-		log.Errorf("failed to verify state root at block %d", idxBlockNum)
-		return err
+		logger.Errorf("failed to verify state root at block %d", blockNum)
+		result.Err = err
+	} else {
+		result.StateRootOK = true
+		logger.Infof("state root verified for block %d", blockNum)
 	}
-	log.Infof("state root verified for block %d", idxBlockNum)
+	return result
+}
 
-	tx := s.db.MustBegin()
+// checkReferentialIntegrity runs each referential integrity check at the given height,
+// returning whether each one passed and the first error encountered
+func checkReferentialIntegrity(db *sqlx.DB, b *ipldeth.Backend, blockNum uint64) (map[string]bool, error) {
+	tx := db.MustBegin()
 	defer tx.Rollback()
-	err = ValidateReferentialIntegrity(tx, idxBlockNum)
+
+	passed := make(map[string]bool)
+	var refErr error
+	for _, check := range ReferentialIntegrityChecks(b.Config.ChainConfig, b.IpldTrieStateDatabase) {
+		err := check.Validate(tx, blockNum)
+		passed[check.Name] = err == nil
+		if err != nil && refErr == nil {
+			refErr = err
+		}
+	}
+	if refErr != nil {
+		log.Errorf("failed to verify referential integrity at block %d", blockNum)
+	} else {
+		log.Infof("referential integrity verified for block %d", blockNum)
+	}
+	return passed, refErr
+}
+
+// validateBatch concurrently validates up to s.workers consecutive heights starting at the given
+// height, limited to those that are at least trail blocks behind the head. The returned errors are
+// ordered by height. If no heights can be validated yet, a ChainNotSyncedError is returned.
+func (s *Service) validateBatch(ctx context.Context, api *ipldeth.PublicEthAPI, from uint64) ([]error, error) {
+	headBlockNum, err := fetchHeadBlockNumber(ctx, api)
 	if err != nil {
-		log.Errorf("failed to verify referential integrity at block %d", idxBlockNum)
-		return err
+		return nil, err
+	}
+	s.status.setHead(headBlockNum)
+	if from+s.trail > headBlockNum {
+		return nil, &ChainNotSyncedError{headBlockNum}
+	}
+
+	count := headBlockNum - s.trail - from + 1
+	if count > s.workers {
+		count = s.workers
+	}
+	if s.toBlock != 0 && from+count-1 > s.toBlock {
+		count = s.toBlock - from + 1
 	}
-	log.Infof("referential integrity verified for block %d", idxBlockNum)
 
+	errs := make([]error, count)
+	wg := new(sync.WaitGroup)
+	for i := uint64(0); i < count; i++ {
+		wg.Add(1)
+		go func(i uint64) {
+			defer wg.Done()
+			errs[i] = s.Validate(ctx, api, from+i)
+		}(i)
+	}
+	wg.Wait()
+	return errs, nil
+}
+
+// handleFailure applies the failure policy to the block at the given height, which failed validation
+// with the given error. Under retry-then-skip the block is retried first, and under halt the failure is
+// marked. It returns whether to stop, and the error remaining after any retries, for the caller to
+// mark as skipped.
+func (s *Service) handleFailure(ctx context.Context, api *ipldeth.PublicEthAPI, blockNum uint64, err error) (bool, error) {
+	if s.failurePolicy == FailurePolicyRetryThenSkip {
+		err = s.retryValidate(ctx, api, blockNum)
+		if err == errStopped {
+			log.Info("stopping ipld-eth-db-validator process")
+			return true, nil
+		}
+	}
+	if err != nil && s.failurePolicy == FailurePolicyHalt {
+		s.markFailed(blockNum, err)
+		return true, nil
+	}
+	return false, err
+}
+
+// retryValidate retries validating a failed block up to s.failureRetries times, waiting
+// s.retryInterval before each attempt. It returns errStopped if the service is stopped meanwhile.
+func (s *Service) retryValidate(ctx context.Context, api *ipldeth.PublicEthAPI, blockNum uint64) error {
+	var err error
+	for i := uint(1); i <= s.failureRetries; i++ {
+		select {
+		case <-s.quitChan:
+			return errStopped
+		case <-time.After(s.retryInterval):
+		}
+		log.Infof("retrying validation of block %d (attempt %d of %d)", blockNum, i, s.failureRetries)
+		if err = s.Validate(ctx, api, blockNum); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// reportFailure logs and counts a block which failed validation
+func (s *Service) reportFailure(blockNum uint64, err error) {
+	log.WithFields(log.Fields{
+		"block":  blockNum,
+		"policy": s.failurePolicy,
+	}).Errorf("validation failed at block %d: %s", blockNum, err)
+	s.summary.Failures = append(s.summary.Failures, BlockFailure{blockNum, err})
+	s.status.setLastFailure(blockNum, err)
+	prom.IncValidationFailures()
+}
+
+// markFailed reports that the block at the given height failed validation, before the service
+// halts. The checkpoint is moved below the block, so that it is validated again after a restart.
+func (s *Service) markFailed(blockNum uint64, err error) {
+	s.reportFailure(blockNum, err)
+	if blockNum > 0 {
+		s.updateCheckpoint(blockNum - 1)
+	}
+}
+
+// markSkipped reports that the block at the given height failed validation and is skipped. The
+// failure is recorded in the block results, so the checkpoint moves past the block.
+func (s *Service) markSkipped(blockNum uint64, err error) {
+	s.reportFailure(blockNum, err)
+	s.updateCheckpoint(blockNum)
+}
+
+// markValidated reports that the block at the given height has been validated, and that all lower
+// heights have been validated or skipped. The checkpoint is advanced to it.
+func (s *Service) markValidated(blockNum uint64) {
+	s.summary.Validated++
+	s.summary.LastValidated = blockNum
+	prom.SetLastValidatedBlock(float64(blockNum))
+	s.updateCheckpoint(blockNum)
 	if s.progressChan != nil {
-		s.progressChan <- idxBlockNum
+		s.progressChan <- blockNum
+	}
+}
+
+// updateCheckpoint saves the checkpoint at the given height. Bounded range runs don't update it,
+// so that they can share a validator ID with a long-running validator.
+func (s *Service) updateCheckpoint(blockNum uint64) {
+	if s.toBlock != 0 {
+		return
+	}
+	if err := saveCheckpoint(s.db, s.validatorID, blockNum); err != nil {
+		log.Errorf("failed to save checkpoint at block %d: %s", blockNum, err)
 	}
-	return nil
 }
 
 // ValidateBlock validates block at the given height
 func ValidateBlock(blockToBeValidated *types.Block, b *ipldeth.Backend, blockNumber uint64) error {
-	state, err := applyTransactions(blockToBeValidated, b)
+	state, receipts, usedGas, err := applyTransactions(blockToBeValidated, b)
 	if err != nil {
 		return err
 	}
+	header := blockToBeValidated.Header()
+
+	if header.WithdrawalsHash != nil {
+		withdrawalsRoot := types.DeriveSha(blockToBeValidated.Withdrawals(), trie.NewStackTrie(nil))
+		if withdrawalsRoot != *header.WithdrawalsHash {
+			return &HeaderMismatchError{blockNumber, "withdrawals root", *header.WithdrawalsHash, withdrawalsRoot}
+		}
+	}
+
+	if usedGas != header.GasUsed {
+		return &HeaderMismatchError{blockNumber, "gas used", header.GasUsed, usedGas}
+	}
+
+	bloom := types.CreateBloom(receipts)
+	if bloom != header.Bloom {
+		return &HeaderMismatchError{blockNumber, "logs bloom",
+			hexutil.Bytes(header.Bloom.Bytes()), hexutil.Bytes(bloom.Bytes())}
+	}
+
+	dbReceiptRoot := types.DeriveSha(receipts, trie.NewStackTrie(nil))
+	if dbReceiptRoot != header.ReceiptHash {
+		return &HeaderMismatchError{blockNumber, "receipts root", header.ReceiptHash, dbReceiptRoot}
+	}
+
+	// The header can be correct while the receipts indexed for the block are not
+	indexedReceipts, err := b.GetReceipts(context.Background(), blockToBeValidated.Hash())
+	if err != nil {
+		return fmt.Errorf("error fetching indexed receipts: %w", err)
+	}
+	indexedReceiptRoot := types.DeriveSha(indexedReceipts, trie.NewStackTrie(nil))
+	if indexedReceiptRoot != dbReceiptRoot {
+		return &ReceiptsMismatchError{blockNumber, len(indexedReceipts), len(receipts), indexedReceiptRoot, dbReceiptRoot}
+	}
 
-	blockStateRoot := blockToBeValidated.Header().Root
 	dbStateRoot := state.IntermediateRoot(true)
-	if blockStateRoot != dbStateRoot {
-		return fmt.Errorf("state roots do not match at block %d", blockNumber)
+	if dbStateRoot != header.Root {
+		return &HeaderMismatchError{blockNumber, "state root", header.Root, dbStateRoot}
 	}
 	return nil
 }
@@ -267,36 +680,99 @@ func fetchHeadBlockNumber(ctx context.Context, api *ipldeth.PublicEthAPI) (uint6
 	return headBlock.NumberU64(), nil
 }
 
+// applyMissingBlockPolicy applies the missing block policy when no block is indexed at the given
+// height. Under the fill-and-revalidate policy, it returns the block once it has been indexed.
+func (s *Service) applyMissingBlockPolicy(ctx context.Context, api *ipldeth.PublicEthAPI, blockNum uint64) (*types.Block, error) {
+	missingErr := &MissingBlockError{blockNum}
+	switch s.missingBlockPolicy {
+	case MissingBlockPolicyRecordAndSkip:
+		return nil, missingErr
+	case MissingBlockPolicyFillAndRevalidate:
+		if err := s.writeStateDiffAt(blockNum); err != nil {
+			return nil, err
+		}
+		return s.waitForBlock(ctx, api, blockNum)
+	default:
+		return nil, missingErr
+	}
+}
+
+// waitForBlock polls for the block at the given height until it is indexed, giving up after
+// the statediffing timeout
+func (s *Service) waitForBlock(ctx context.Context, api *ipldeth.PublicEthAPI, blockNum uint64) (*types.Block, error) {
+	deadline := time.After(s.stateDiffTimeout)
+	for {
+		block, err := api.B.BlockByNumber(ctx, rpc.BlockNumber(blockNum))
+		if err != nil {
+			return nil, err
+		}
+		if block != nil {
+			log.Infof("block %d indexed after writeStateDiffAt", blockNum)
+			return block, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-deadline:
+			return nil, &MissingBlockError{blockNum}
+		case <-time.After(s.retryInterval):
+		}
+	}
+}
+
 // writeStateDiffAt calls out to a statediffing geth client to fill in a gap in the index
 func (s *Service) writeStateDiffAt(height uint64) error {
-	if !s.stateDiffMissingBlock {
-		return nil
+	log.Warnf("calling writeStateDiffAt at block %d", height)
+	if err := writeStateDiffBatch(context.Background(), s.ethClient, s.stateDiffTimeout, []uint64{height})[height]; err != nil {
+		log.Errorf("writeStateDiffAt %d failed with err %s", height, err)
+		return err
 	}
+	return nil
+}
 
-	var data json.RawMessage
+// writeStateDiffBatch sends a batch of writeStateDiffAt calls for the given heights to a
+// statediffing geth client, returning the error for each height whose call failed
+func writeStateDiffBatch(ctx context.Context, client *rpc.Client, timeout time.Duration, heights []uint64) map[uint64]error {
 	params := statediff.Params{
 		IncludeBlock:    true,
 		IncludeReceipts: true,
 		IncludeTD:       true,
 		IncludeCode:     true,
 	}
+	batch := make([]rpc.BatchElem, len(heights))
+	for i, height := range heights {
+		batch[i] = rpc.BatchElem{
+			Method: "statediff_writeStateDiffAt",
+			Args:   []interface{}{height, params},
+			Result: new(json.RawMessage),
+		}
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), s.stateDiffTimeout)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
-	log.Warnf("calling writeStateDiffAt at block %d", height)
-	if err := s.ethClient.CallContext(ctx, &data, "statediff_writeStateDiffAt", height, params); err != nil {
-		log.Errorf("writeStateDiffAt %d failed with err %s", height, err)
-		return err
+	failed := make(map[uint64]error)
+	if err := client.BatchCallContext(ctx, batch); err != nil {
+		for _, height := range heights {
+			failed[height] = err
+		}
+		return failed
 	}
-	return nil
+	for i, elem := range batch {
+		if elem.Error != nil {
+			failed[heights[i]] = elem.Error
+		}
+	}
+	return failed
 }
 
 // applyTransaction attempts to apply block transactions to the given state database
-// and uses the input parameters for its environment. It returns the stateDB of parent with applied txs.
-func applyTransactions(block *types.Block, backend *ipldeth.Backend) (*ipldstate.StateDB, error) {
+// and uses the input parameters for its environment. It returns the stateDB of parent with applied txs,
+// along with the receipts generated by the replay and the total gas used.
+func applyTransactions(block *types.Block, backend *ipldeth.Backend) (*ipldstate.StateDB, types.Receipts, uint64, error) {
 	if block.NumberU64() == 0 {
-		return nil, errors.New("no transaction in genesis")
+		return nil, nil, 0, errors.New("no transaction in genesis")
 	}
 
 	// Create the parent state database
@@ -304,22 +780,28 @@ func applyTransactions(block *types.Block, backend *ipldeth.Backend) (*ipldstate
 	nrOrHash := rpc.BlockNumberOrHash{BlockHash: &parentHash}
 	statedb, _, err := backend.IPLDTrieStateDBAndHeaderByNumberOrHash(context.Background(), nrOrHash)
 	if err != nil {
-		return nil, fmt.Errorf("error accessing state DB: %w", err)
+		return nil, nil, 0, fmt.Errorf("error accessing state DB: %w", err)
 	}
+	applyIrregularStateChanges(backend.Config.ChainConfig, block.Header(), statedb)
 
-	var gp core.GasPool
+	var (
+		gp       core.GasPool
+		usedGas  uint64
+		receipts types.Receipts
+	)
 	gp.AddGas(block.GasLimit())
 
 	signer := types.MakeSigner(backend.Config.ChainConfig, block.Number())
 	blockContext := core.NewEVMBlockContext(block.Header(), backend, getAuthor(backend, block.Header()))
 	evm := vm.NewEVM(blockContext, vm.TxContext{}, statedb, backend.Config.ChainConfig, vm.Config{})
-	rules := backend.Config.ChainConfig.Rules(block.Number(), true, block.Time())
+	isPoS := isPoSBlock(backend.Config.ChainConfig, block.Header())
+	rules := backend.Config.ChainConfig.Rules(block.Number(), isPoS, block.Time())
 
 	// Iterate over and process the individual transactions
 	for i, tx := range block.Transactions() {
 		msg, err := core.TransactionToMessage(tx, signer, block.BaseFee())
 		if err != nil {
-			return nil, fmt.Errorf("error converting transaction to message: %w", err)
+			return nil, nil, 0, fmt.Errorf("error converting transaction to message: %w", err)
 		}
 		statedb.SetTxContext(tx.Hash(), i)
 		statedb.Prepare(rules, msg.From, block.Coinbase(), msg.To, nil, nil)
@@ -327,16 +809,56 @@ func applyTransactions(block *types.Block, backend *ipldeth.Backend) (*ipldstate
 		// Create a new context to be used in the EVM environment.
 		evm.Reset(core.NewEVMTxContext(msg), statedb)
 		// Apply the transaction to the current state (included in the env).
-		if _, err := core.ApplyMessage(evm, msg, &gp); err != nil {
-			return nil, fmt.Errorf("transaction %#x failed: %w", tx.Hash(), err)
+		result, err := core.ApplyMessage(evm, msg, &gp)
+		if err != nil {
+			return nil, nil, 0, fmt.Errorf("transaction %#x failed: %w", tx.Hash(), err)
 		}
+		usedGas += result.UsedGas
+
+		receipts = append(receipts, makeReceipt(backend.Config.ChainConfig, block, tx, msg, result, statedb, usedGas))
 	}
 
-	if backend.Config.ChainConfig.Ethash != nil {
+	// There are no block rewards after the merge
+	if backend.Config.ChainConfig.Ethash != nil && !isPoS {
 		accumulateRewards(backend.Config.ChainConfig, statedb, block.Header(), block.Uncles())
 	}
+	applyWithdrawals(statedb, block.Withdrawals())
+
+	return statedb, receipts, usedGas, nil
+}
+
+// makeReceipt builds the receipt for a transaction that has just been applied, in the same way
+// as geth's state processor. usedGas is the cumulative gas used in the block including this tx.
+func makeReceipt(config *params.ChainConfig, block *types.Block, tx *types.Transaction, msg *core.Message,
+	result *core.ExecutionResult, statedb *ipldstate.StateDB, usedGas uint64) *types.Receipt {
+	// Update the state with pending changes; pre-Byzantium receipts commit to the intermediate root
+	var root []byte
+	if config.IsByzantium(block.Number()) {
+		statedb.Finalise(true)
+	} else {
+		root = statedb.IntermediateRoot(config.IsEIP158(block.Number())).Bytes()
+	}
+
+	receipt := &types.Receipt{Type: tx.Type(), PostState: root, CumulativeGasUsed: usedGas}
+	if result.Failed() {
+		receipt.Status = types.ReceiptStatusFailed
+	} else {
+		receipt.Status = types.ReceiptStatusSuccessful
+	}
+	receipt.TxHash = tx.Hash()
+	receipt.GasUsed = result.UsedGas
+
+	// If the transaction created a contract, store the creation address in the receipt
+	if msg.To == nil {
+		receipt.ContractAddress = crypto.CreateAddress(msg.From, tx.Nonce())
+	}
 
-	return statedb, nil
+	receipt.Logs = statedb.GetLogs(tx.Hash(), block.NumberU64(), block.Hash())
+	receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
+	receipt.BlockHash = block.Hash()
+	receipt.BlockNumber = block.Number()
+	receipt.TransactionIndex = uint(statedb.TxIndex())
+	return receipt
 }
 
 // accumulateRewards credits the coinbase of the given block with the mining
@@ -370,6 +892,16 @@ func accumulateRewards(config *params.ChainConfig, state *ipldstate.StateDB, hea
 	state.AddBalance(header.Coinbase, reward)
 }
 
+// applyWithdrawals credits the beacon chain withdrawals (EIP-4895) included in a block.
+// Withdrawal amounts are denominated in Gwei.
+func applyWithdrawals(state *ipldstate.StateDB, withdrawals types.Withdrawals) {
+	for _, w := range withdrawals {
+		amount := new(big.Int).SetUint64(w.Amount)
+		amount.Mul(amount, big.NewInt(params.GWei))
+		state.AddBalance(w.Address, amount)
+	}
+}
+
 func setChainConfig(ghash common.Hash) *params.ChainConfig {
 	switch {
 	case ghash == params.MainnetGenesisHash:
@@ -394,12 +926,26 @@ func getAuthor(b *ipldeth.Backend, header *types.Header) *common.Address {
 	return &author
 }
 
+// getEngine returns the consensus engine for the configured chain. On chains that have a terminal
+// total difficulty configured, the pre-merge engine is wrapped so that proof-of-stake rules are
+// used for post-merge blocks.
 func getEngine(b *ipldeth.Backend) consensus.Engine {
+	var engine consensus.Engine
 	// TODO: add logic for other engines
 	if b.Config.ChainConfig.Clique != nil {
-		engine := clique.New(b.Config.ChainConfig.Clique, nil)
-		return engine
+		engine = clique.New(b.Config.ChainConfig.Clique, nil)
+	} else {
+		engine = ethash.NewFaker()
 	}
 
-	return ethash.NewFaker()
+	if b.Config.ChainConfig.TerminalTotalDifficulty != nil {
+		engine = beacon.New(engine)
+	}
+	return engine
+}
+
+// isPoSBlock returns whether the block with the given header was produced under proof-of-stake
+// rules, i.e. the chain has a terminal total difficulty and the block's difficulty is zero
+func isPoSBlock(config *params.ChainConfig, header *types.Header) bool {
+	return config.TerminalTotalDifficulty != nil && beacon.IsPoSHeader(header)
 }
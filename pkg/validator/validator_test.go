@@ -2,14 +2,25 @@ package validator_test
 
 import (
 	"context"
+	"errors"
 	"math/big"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/prometheus/client_golang/prometheus"
 
 	"github.com/cerc-io/plugeth-statediff/indexer/ipld"
+	"github.com/cerc-io/plugeth-statediff/test_helpers"
 	sdtypes "github.com/cerc-io/plugeth-statediff/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/beacon"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/rpc"
 
@@ -18,6 +29,7 @@ import (
 
 	"github.com/cerc-io/ipld-eth-db-validator/v5/internal/chaingen"
 	"github.com/cerc-io/ipld-eth-db-validator/v5/internal/helpers"
+	"github.com/cerc-io/ipld-eth-db-validator/v5/pkg/prom"
 	"github.com/cerc-io/ipld-eth-db-validator/v5/pkg/validator"
 )
 
@@ -28,7 +40,6 @@ const (
 
 var (
 	chainConfig = TestChainConfig
-	mockTD      = big.NewInt(1337)
 	testDB      = rawdb.NewMemoryDatabase()
 )
 
@@ -90,10 +101,9 @@ func setupStateValidator(t *testing.T) *sqlx.DB {
 	}()
 
 	if err := helpers.IndexChain(indexer, helpers.IndexChainParams{
-		StateCache:      chain.StateCache(),
-		Blocks:          blocks,
-		Receipts:        receipts,
-		TotalDifficulty: mockTD,
+		StateCache: chain.StateCache(),
+		Blocks:     blocks,
+		Receipts:   receipts,
 	}); err != nil {
 		t.Fatal(err)
 	}
@@ -109,12 +119,14 @@ func setupStateValidator(t *testing.T) *sqlx.DB {
 func TestStateValidation(t *testing.T) {
 	db := setupStateValidator(t)
 
-	t.Run("Validator", func(t *testing.T) {
-		api, err := validator.EthAPI(context.Background(), db, chainConfig)
-		if err != nil {
-			t.Fatal(err)
-		}
+	// The backend closes its connection, and must be closed so another can be created
+	api, err := validator.EthAPI(context.Background(), helpers.SetupDB(), chainConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer api.B.Close()
 
+	t.Run("Validator", func(t *testing.T) {
 		for i := uint64(startBlock); i <= chainLength; i++ {
 			blockToBeValidated, err := api.B.BlockByNumber(context.Background(), rpc.BlockNumber(i))
 			if err != nil {
@@ -130,4 +142,304 @@ func TestStateValidation(t *testing.T) {
 			}
 		}
 	})
+
+	// Block 2 has several transactions, including a contract creation
+	const checkedBlock = 2
+	block, err := api.B.BlockByNumber(context.Background(), rpc.BlockNumber(checkedBlock))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Header mismatch", func(t *testing.T) {
+		testCases := []struct {
+			field  string
+			modify func(*types.Header)
+		}{
+			{"gas used", func(header *types.Header) { header.GasUsed++ }},
+			{"logs bloom", func(header *types.Header) { header.Bloom[0] ^= 1 }},
+			{"receipts root", func(header *types.Header) { header.ReceiptHash = common.HexToHash("0x1") }},
+		}
+		for _, tc := range testCases {
+			t.Run(tc.field, func(t *testing.T) {
+				header := block.Header()
+				tc.modify(header)
+				modified := types.NewBlockWithHeader(header).WithBody(block.Transactions(), block.Uncles())
+
+				err := validator.ValidateBlock(modified, api.B, checkedBlock)
+				var mismatchErr *validator.HeaderMismatchError
+				if !errors.As(err, &mismatchErr) {
+					t.Fatalf("expected a HeaderMismatchError, got %v", err)
+				}
+				if mismatchErr.Field != tc.field {
+					t.Fatalf("expected mismatched field %q, got %q", tc.field, mismatchErr.Field)
+				}
+			})
+		}
+	})
+
+	t.Run("Indexed receipts mismatch", func(t *testing.T) {
+		var cids []string
+		if err := db.Select(&cids, `SELECT cid FROM eth.receipt_cids WHERE header_id = $1`, block.Hash().String()); err != nil {
+			t.Fatal(err)
+		}
+		if len(cids) < 2 {
+			t.Fatalf("expected at least 2 receipts at block %d, got %d", checkedBlock, len(cids))
+		}
+		// Swap the indexed data of two receipts, and swap it back afterwards
+		swap := func() {
+			if _, err := db.Exec(swapIPLDDataPgStr, cids[0], cids[1]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		swap()
+		defer swap()
+
+		err := validator.ValidateBlock(block, api.B, checkedBlock)
+		var mismatchErr *validator.ReceiptsMismatchError
+		if !errors.As(err, &mismatchErr) {
+			t.Fatalf("expected a ReceiptsMismatchError, got %v", err)
+		}
+	})
+}
+
+// swapIPLDDataPgStr swaps the data of the IPLD blocks with the two given keys
+const swapIPLDDataPgStr = `UPDATE ipld.blocks SET data = CASE key
+		WHEN $1 THEN (SELECT data FROM ipld.blocks WHERE key = $2 LIMIT 1)
+		ELSE (SELECT data FROM ipld.blocks WHERE key = $1 LIMIT 1)
+	END
+	WHERE key IN ($1, $2)`
+
+// Post-merge blocks are replayed without block rewards, with the fees going to the author given
+// by the beacon engine
+func TestMergeTransition(t *testing.T) {
+	const mergeBlock = 6
+	mergeConfig := *chainConfig
+	posCoinbase := common.HexToAddress("0x5e")
+
+	gen := chaingen.DefaultGenContext(&mergeConfig, rawdb.NewMemoryDatabase())
+	gen.Engine = beacon.New(ethash.NewFaker())
+	gen.AddFunction(func(i int, block *core.BlockGen) {
+		if i+1 < mergeBlock {
+			return
+		}
+		block.SetPoS()
+		block.SetCoinbase(posCoinbase)
+		if i+1 == mergeBlock {
+			tx, err := gen.CreateSendTx(crypto.PubkeyToAddress(test_helpers.TestBankKey.PublicKey), posCoinbase, big.NewInt(0))
+			if err != nil {
+				panic(err)
+			}
+			block.AddTx(tx)
+		}
+	})
+	blocks, receipts, chain := gen.MakeChain(mergeBlock + 1)
+	t.Cleanup(func() {
+		chain.Stop()
+	})
+
+	// The terminal total difficulty is reached by the last proof-of-work block
+	ttd := new(big.Int)
+	for _, block := range blocks[:mergeBlock] {
+		ttd.Add(ttd, block.Difficulty())
+	}
+	mergeConfig.TerminalTotalDifficulty = ttd
+	for _, block := range blocks[mergeBlock:] {
+		if block.Difficulty().Sign() != 0 {
+			t.Fatalf("expected block %d to be post-merge", block.NumberU64())
+		}
+	}
+
+	// The coinbase only earns the fees of the merge block's transaction
+	headState, err := chain.StateAt(blocks[len(blocks)-1].Root())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance := headState.GetBalance(posCoinbase); balance.Sign() == 0 || balance.Cmp(ethash.ConstantinopleBlockReward) >= 0 {
+		t.Fatalf("expected the post-merge coinbase to earn only fees, got %s", balance)
+	}
+
+	indexer, err := helpers.TestStateDiffIndexer(context.Background(), &mergeConfig, gen.Genesis.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := helpers.IndexChain(indexer, helpers.IndexChainParams{
+		StateCache: chain.StateCache(),
+		Blocks:     blocks,
+		Receipts:   receipts,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	db := helpers.SetupDB()
+	t.Cleanup(func() {
+		helpers.TearDownDB(db)
+	})
+
+	api, err := validator.EthAPI(context.Background(), helpers.SetupDB(), &mergeConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer api.B.Close()
+
+	for i := uint64(startBlock); i < uint64(len(blocks)); i++ {
+		block, err := api.B.BlockByNumber(context.Background(), rpc.BlockNumber(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := validator.ValidateBlock(block, api.B, i); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+var initMetrics sync.Once
+
+// clearValidator deletes the rows of the given validator from the validator tables once the test is done
+func clearValidator(t *testing.T, db *sqlx.DB, validatorID string) {
+	t.Cleanup(func() {
+		for _, table := range []string{"checkpoints", "block_results", "trie_checks"} {
+			db.Exec(`DELETE FROM validator.`+table+` WHERE validator_id = $1`, validatorID)
+		}
+	})
+}
+
+// serviceConfig returns the config of a validator service over the test chain
+func serviceConfig(validatorID string, from uint64) *validator.Config {
+	return &validator.Config{
+		DBConfig:           helpers.TestDBConfig,
+		ValidatorID:        validatorID,
+		ChainConfig:        chainConfig,
+		FromBlock:          from,
+		Workers:            1,
+		RetryInterval:      10 * time.Millisecond,
+		MissingBlockPolicy: validator.MissingBlockPolicyRecordAndSkip,
+		FailurePolicy:      validator.FailurePolicyHalt,
+	}
+}
+
+// runService runs a validator service with the given config until it stops by itself, or until
+// the block at stopAt is reported as validated if it is not zero. It returns the service and the
+// heights reported as validated, in order.
+func runService(t *testing.T, cfg *validator.Config, stopAt uint64) (*validator.Service, []uint64) {
+	progress := make(chan uint64)
+	service, err := validator.NewService(cfg, progress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wg := new(sync.WaitGroup)
+	wg.Add(1)
+	go service.Start(context.Background(), wg)
+
+	var validated []uint64
+	var stopped bool
+	timeout := time.After(time.Minute)
+	for {
+		select {
+		case blockNum, ok := <-progress:
+			if !ok {
+				wg.Wait()
+				return service, validated
+			}
+			validated = append(validated, blockNum)
+			if stopAt != 0 && blockNum >= stopAt && !stopped {
+				service.Stop()
+				stopped = true
+			}
+		case <-timeout:
+			if !stopped {
+				service.Stop()
+			}
+			t.Fatalf("timed out running validator %s, validated %v", cfg.ValidatorID, validated)
+		}
+	}
+}
+
+// metricValue returns the value of the validator stats gauge or counter with the given name
+func metricValue(t *testing.T, name string) float64 {
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		if family.GetName() != "ipld_eth_state_snapshot_stats_"+name {
+			continue
+		}
+		metric := family.GetMetric()[0]
+		if gauge := metric.GetGauge(); gauge != nil {
+			return gauge.GetValue()
+		}
+		return metric.GetCounter().GetValue()
+	}
+	t.Fatalf("metric %s not found", name)
+	return 0
+}
+
+func assertHeights(t *testing.T, expected, actual []uint64) {
+	t.Helper()
+	if len(expected) != len(actual) {
+		t.Fatalf("expected heights %v, got %v", expected, actual)
+	}
+	for i := range expected {
+		if expected[i] != actual[i] {
+			t.Fatalf("expected heights %v, got %v", expected, actual)
+		}
+	}
+}
+
+// With several workers, progress is only reported over a contiguous range of validated heights
+func TestContiguousProgress(t *testing.T) {
+	db := setupStateValidator(t)
+	initMetrics.Do(prom.Init)
+
+	t.Run("Unsynced heights", func(t *testing.T) {
+		const validatorID = "test-progress-unsynced"
+		clearValidator(t, db, validatorID)
+
+		// Only the heights up to 5 are far enough behind the head
+		cfg := serviceConfig(validatorID, 3)
+		cfg.Workers = 4
+		cfg.Trail = chainLength - 5
+		_, validated := runService(t, cfg, 5)
+
+		assertHeights(t, []uint64{3, 4, 5}, validated)
+		if last := metricValue(t, "last_validated_block"); last != 5 {
+			t.Fatalf("expected last validated block 5, got %v", last)
+		}
+		if checkpoint, _ := getCheckpoint(t, db, validatorID); checkpoint != 5 {
+			t.Fatalf("expected checkpoint at 5, got %d", checkpoint)
+		}
+	})
+
+	t.Run("Failed height", func(t *testing.T) {
+		const validatorID = "test-progress-failed"
+		clearValidator(t, db, validatorID)
+
+		// Block 5 fails, while block 6 in the same batch passes
+		if _, err := db.Exec(`UPDATE eth.transaction_cids SET src = $1 WHERE block_number = 5`,
+			common.HexToAddress("0x1").Hex()); err != nil {
+			t.Fatal(err)
+		}
+		cfg := serviceConfig(validatorID, 3)
+		cfg.Workers = 4
+		service, validated := runService(t, cfg, 0)
+
+		assertHeights(t, []uint64{3, 4}, validated)
+		summary := service.Summary()
+		if len(summary.Failures) != 1 || summary.Failures[0].BlockNumber != 5 {
+			t.Fatalf("expected block 5 to fail, got %s", &summary)
+		}
+		var passed bool
+		if err := db.Get(&passed, `SELECT passed FROM validator.block_results WHERE validator_id = $1 AND block_number = 6`,
+			validatorID); err != nil {
+			t.Fatal(err)
+		}
+		if !passed {
+			t.Fatal("expected block 6 to pass in the same batch")
+		}
+		if last := metricValue(t, "last_validated_block"); last != 4 {
+			t.Fatalf("expected last validated block 4, got %v", last)
+		}
+		if checkpoint, _ := getCheckpoint(t, db, validatorID); checkpoint != 4 {
+			t.Fatalf("expected checkpoint at 4, got %d", checkpoint)
+		}
+	})
 }
@@ -0,0 +1,54 @@
+// VulcanizeDB
+// Copyright © 2023 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package validator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// withWithdrawals returns the block along with its withdrawals. The v5 schema does not index
+// withdrawals, so if the header commits to any they are fetched from the given node. They are
+// checked against the indexed header's withdrawals root when the block is validated.
+func withWithdrawals(ctx context.Context, client *rpc.Client, block *types.Block) (*types.Block, error) {
+	withdrawalsHash := block.Header().WithdrawalsHash
+	if withdrawalsHash == nil || *withdrawalsHash == types.EmptyRootHash {
+		return block, nil
+	}
+	if client == nil {
+		return nil, &MissingWithdrawalsError{block.NumberU64()}
+	}
+
+	var raw json.RawMessage
+	if err := client.CallContext(ctx, &raw, "eth_getBlockByHash", block.Hash(), false); err != nil {
+		return nil, fmt.Errorf("error fetching withdrawals of block %d: %w", block.NumberU64(), err)
+	}
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, fmt.Errorf("block %d (%s) not found on node", block.NumberU64(), block.Hash())
+	}
+	var body struct {
+		Withdrawals types.Withdrawals `json:"withdrawals"`
+	}
+	if err := json.Unmarshal(raw, &body); err != nil {
+		return nil, fmt.Errorf("error decoding withdrawals of block %d: %w", block.NumberU64(), err)
+	}
+	return block.WithWithdrawals(body.Withdrawals), nil
+}
@@ -0,0 +1,154 @@
+package validator_test
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/beacon"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"github.com/cerc-io/ipld-eth-db-validator/v5/internal/chaingen"
+	"github.com/cerc-io/ipld-eth-db-validator/v5/internal/helpers"
+	"github.com/cerc-io/ipld-eth-db-validator/v5/pkg/validator"
+)
+
+var withdrawalAddr = common.HexToAddress("0xee")
+
+// withdrawalsAPI serves the withdrawals of the known blocks, in the place of a statediffing node
+type withdrawalsAPI struct {
+	blocks map[common.Hash]*types.Block
+	// Applied to the withdrawals before they are served, if set
+	modify func(types.Withdrawals) types.Withdrawals
+}
+
+func (api *withdrawalsAPI) GetBlockByHash(hash common.Hash, fullTx bool) (map[string]interface{}, error) {
+	block, ok := api.blocks[hash]
+	if !ok {
+		return nil, nil
+	}
+	withdrawals := block.Withdrawals()
+	if api.modify != nil {
+		withdrawals = api.modify(withdrawals)
+	}
+	return map[string]interface{}{"withdrawals": withdrawals}, nil
+}
+
+// Post-Shanghai blocks are replayed with their withdrawals, which are not indexed and must be
+// fetched from the node
+func TestWithdrawals(t *testing.T) {
+	shanghaiConfig := *chainConfig
+	shanghaiConfig.LondonBlock = big.NewInt(0)
+	shanghaiConfig.TerminalTotalDifficulty = big.NewInt(0)
+	shanghaiConfig.TerminalTotalDifficultyPassed = true
+	shanghaiTime := uint64(0)
+	shanghaiConfig.ShanghaiTime = &shanghaiTime
+
+	gen := chaingen.DefaultGenContext(&shanghaiConfig, rawdb.NewMemoryDatabase())
+	gen.Engine = beacon.New(ethash.NewFaker())
+	gen.AddFunction(func(i int, block *core.BlockGen) {
+		block.SetPoS()
+		block.AddWithdrawal(&types.Withdrawal{
+			Validator: uint64(i),
+			Address:   withdrawalAddr,
+			Amount:    1337,
+		})
+	})
+	blocks, receipts, chain := gen.MakeChain(3)
+	t.Cleanup(func() {
+		chain.Stop()
+	})
+
+	indexer, err := helpers.TestStateDiffIndexer(context.Background(), &shanghaiConfig, gen.Genesis.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := helpers.IndexChain(indexer, helpers.IndexChainParams{
+		StateCache: chain.StateCache(),
+		Blocks:     blocks,
+		Receipts:   receipts,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	db := helpers.SetupDB()
+	t.Cleanup(func() {
+		helpers.TearDownDB(db)
+	})
+
+	api, err := validator.EthAPI(context.Background(), helpers.SetupDB(), &shanghaiConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer api.B.Close()
+
+	stub := &withdrawalsAPI{blocks: make(map[common.Hash]*types.Block)}
+	for _, block := range blocks {
+		stub.blocks[block.Hash()] = block
+	}
+	server := rpc.NewServer()
+	if err := server.RegisterName("eth", stub); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Stop()
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	indexedBlock := func(t *testing.T, i uint64) *types.Block {
+		block, err := api.B.BlockByNumber(context.Background(), rpc.BlockNumber(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(block.Withdrawals()) != 0 {
+			t.Fatalf("expected block %d to be indexed without withdrawals", i)
+		}
+		return block
+	}
+
+	t.Run("Withdrawals credited", func(t *testing.T) {
+		for i := uint64(1); i < uint64(len(blocks)); i++ {
+			result := validator.CheckBlock(context.Background(), db, api.B, client, indexedBlock(t, i))
+			if !result.StateRootOK {
+				t.Fatalf("expected block %d to replay with its withdrawals, got %v", i, result.Err)
+			}
+		}
+	})
+
+	t.Run("Withdrawals root mismatch", func(t *testing.T) {
+		stub.modify = func(withdrawals types.Withdrawals) types.Withdrawals {
+			modified := make(types.Withdrawals, len(withdrawals))
+			for i, w := range withdrawals {
+				cpy := *w
+				cpy.Amount++
+				modified[i] = &cpy
+			}
+			return modified
+		}
+		defer func() { stub.modify = nil }()
+
+		result := validator.CheckBlock(context.Background(), db, api.B, client, indexedBlock(t, 1))
+		var mismatchErr *validator.HeaderMismatchError
+		if !errors.As(result.Err, &mismatchErr) {
+			t.Fatalf("expected a HeaderMismatchError, got %v", result.Err)
+		}
+		if mismatchErr.Field != "withdrawals root" {
+			t.Fatalf("expected mismatched field %q, got %q", "withdrawals root", mismatchErr.Field)
+		}
+	})
+
+	t.Run("No client", func(t *testing.T) {
+		result := validator.CheckBlock(context.Background(), db, api.B, nil, indexedBlock(t, 1))
+		var missingErr *validator.MissingWithdrawalsError
+		if !errors.As(result.Err, &missingErr) {
+			t.Fatalf("expected a MissingWithdrawalsError, got %v", result.Err)
+		}
+		if missingErr.BlockNumber != 1 {
+			t.Fatalf("expected block 1, got %d", missingErr.BlockNumber)
+		}
+	})
+}
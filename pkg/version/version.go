@@ -0,0 +1,81 @@
+// VulcanizeDB
+// Copyright © 2023 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package version
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+const (
+	VersionMajor = 5       // Major version component of the current release
+	VersionMinor = 1       // Minor version component of the current release
+	VersionPatch = 0       // Patch version component of the current release
+	VersionMeta  = "alpha" // Version metadata to append to the version string
+)
+
+// Version holds the textual version string.
+var Version = func() string {
+	return fmt.Sprintf("%d.%d.%d", VersionMajor, VersionMinor, VersionPatch)
+}()
+
+// buildVersion is set at build time to the version of the source the binary is built from, e.g.
+//
+//	go build -ldflags "-X github.com/cerc-io/ipld-eth-db-validator/v5/pkg/version.buildVersion=$(git describe --tags --dirty)"
+var buildVersion string
+
+// VersionWithMeta holds the textual version string including the metadata. It identifies the build
+// of the binary: the version set at build time if any, otherwise the module version from the build
+// info, otherwise the release version along with the VCS revision the binary was built from.
+var VersionWithMeta = func() string {
+	if buildVersion != "" {
+		return buildVersion
+	}
+
+	v := Version
+	if VersionMeta != "" {
+		v += "-" + VersionMeta
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return v
+	}
+	if info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+
+	var revision string
+	var modified bool
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			modified = setting.Value == "true"
+		}
+	}
+	if revision != "" {
+		if len(revision) > 12 {
+			revision = revision[:12]
+		}
+		v += "+" + revision
+		if modified {
+			v += ".dirty"
+		}
+	}
+	return v
+}()